@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// emptyTreeSHA is git's hash of the empty tree, used as the base when amending a root commit
+const emptyTreeSHA = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// amendBase returns the revision the amended commit is described against: the parent of HEAD,
+// or the empty tree when HEAD is a root commit
+func amendBase() (string, error) {
+	if err := gitCommand("", "rev-parse", "--verify", "--quiet", "HEAD").Run(); err != nil {
+		return "", fmt.Errorf("there is no commit to amend")
+	}
+	if err := gitCommand("", "rev-parse", "--verify", "--quiet", "HEAD~1").Run(); err != nil {
+		Log(DEBUG, "HEAD is a root commit, describing it against the empty tree")
+		return emptyTreeSHA, nil
+	}
+	return "HEAD~1", nil
+}
+
+// previousCommitMessage returns the full message of the commit being amended
+func previousCommitMessage() (string, error) {
+	output, err := gitCommand("", "log", "-1", "--format=%B", "HEAD").Output()
+	if err != nil {
+		return "", &GitError{Err: fmt.Errorf("failed to read the message of the previous commit: %v", err)}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// amendContext asks the LLM to refine the message of the commit being amended rather than
+// write a new one, so the original intent is kept
+func amendContext(previousMessage string) string {
+	return fmt.Sprintf("This amends the previous commit, whose message was:\n\n%s\n\n"+
+		"Refine that message so it also covers any changes it doesn't mention yet, keeping its intent, "+
+		"structure and wording where they still apply, rather than writing a new message.", previousMessage)
+}
+
+// confirmAmend lists the staged files that will be folded into the previous commit and asks
+// for confirmation. With nothing staged, the amend only rewrites the message.
+func confirmAmend() (bool, error) {
+	files, err := getStagedFiles()
+	if err != nil {
+		return false, err
+	}
+	if len(files) == 0 {
+		Log(INFO, "Nothing staged, amending only the message of the previous commit")
+		return true, nil
+	}
+
+	fmt.Printf("These staged files will be folded into the previous commit (%s):\n", commitSubject("HEAD"))
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	return confirm("Amend the previous commit with these changes?"), nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// PresetAnswer answers, without asking, every clarifying question that contains Pattern
+// (case-insensitively)
+type PresetAnswer struct {
+	Pattern string
+	Answer  string
+}
+
+// answerListFlag collects the values of the repeatable -answer flag, each "pattern=answer".
+// Unlike stringListFlag, values aren't split on commas, as answers are free text.
+type answerListFlag []PresetAnswer
+
+func (l *answerListFlag) String() string {
+	var values []string
+	for _, a := range *l {
+		values = append(values, a.Pattern+"="+a.Answer)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *answerListFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected pattern=answer, got %q", value)
+	}
+	pattern, answer := strings.TrimSpace(parts[0]), parts[1]
+	if pattern == "" {
+		return fmt.Errorf("empty pattern in %q", value)
+	}
+	*l = append(*l, PresetAnswer{Pattern: pattern, Answer: strings.TrimSpace(answer)})
+	return nil
+}
+
+// loadAnswersFile reads preset answers from a JSON object mapping question patterns to answers,
+// e.g. {"ticket": "TEAM-123", "breaking change": "No"}. The answers keep the order of the file,
+// so of two equally long matching patterns, the later one wins.
+func loadAnswersFile(path string) ([]PresetAnswer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers file: %v", err)
+	}
+	answers, err := parseAnswers(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid answers file %s: %v", path, err)
+	}
+	return answers, nil
+}
+
+// parseAnswers reads the pattern/answer pairs of a JSON object in the order they appear,
+// which unmarshaling into a map would lose
+func parseAnswers(data []byte) ([]PresetAnswer, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object mapping patterns to answers")
+	}
+	var answers []PresetAnswer
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		pattern := strings.TrimSpace(token.(string))
+		var answer string
+		if err := decoder.Decode(&answer); err != nil {
+			return nil, fmt.Errorf("answer for %q: %v", pattern, err)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("empty pattern")
+		}
+		answers = append(answers, PresetAnswer{Pattern: pattern, Answer: strings.TrimSpace(answer)})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+// matchPresetAnswer returns the answer whose pattern the question contains. The longest
+// matching pattern wins; of equally long ones, the last given (so -answer flags, which
+// follow the -answers file, override it).
+func matchPresetAnswer(question string, answers []PresetAnswer) (string, bool) {
+	question = strings.ToLower(question)
+	best := -1
+	for i, a := range answers {
+		if !strings.Contains(question, strings.ToLower(a.Pattern)) {
+			continue
+		}
+		if best == -1 || len(a.Pattern) >= len(answers[best].Pattern) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return "", false
+	}
+	return answers[best].Answer, true
+}
+
+// answerQuestions fills in answers to the LLM's questions, first from the preset answers, then
+// by asking on stdin. Without a terminal to ask on, questions without a preset answer are skipped.
+func answerQuestions(questions []QuestionResponse, presets []PresetAnswer) []QuestionResponse {
+	var unanswered []int
+	for i := range questions {
+		if answer, ok := matchPresetAnswer(questions[i].Question, presets); ok {
+			Log(INFO, "Using preset answer for question: %s", questions[i].Question)
+			questions[i].Answer = answer
+			continue
+		}
+		unanswered = append(unanswered, i)
+	}
+
+	if len(unanswered) > 0 {
+		if stdinIsTerminal() {
+			asked := make([]QuestionResponse, len(unanswered))
+			for j, i := range unanswered {
+				asked[j] = questions[i]
+			}
+			asked = askUserQuestions(asked)
+			for j, i := range unanswered {
+				questions[i].Answer = asked[j].Answer
+			}
+		} else {
+			fmt.Println("\nSkipping questions without a preset answer, as stdin isn't a terminal (see -answer and -answers):")
+			for _, i := range unanswered {
+				fmt.Printf("  %s\n", questions[i].Question)
+			}
+		}
+	}
+
+	printAnswerSummary(questions)
+	return questions
+}
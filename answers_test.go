@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadAnswersFileKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "answers.json", `{"ticket": " TEAM-1 ", "breaking": "No", "Ticket": "TEAM-2"}`)
+
+	answers, err := loadAnswersFile(filepath.Join(dir, "answers.json"))
+	if err != nil {
+		t.Fatalf("loadAnswersFile() error: %v", err)
+	}
+	want := []PresetAnswer{{"ticket", "TEAM-1"}, {"breaking", "No"}, {"Ticket", "TEAM-2"}}
+	if !reflect.DeepEqual(answers, want) {
+		t.Errorf("loadAnswersFile() = %v, want %v", answers, want)
+	}
+	// Equally long patterns: the later one in the file wins, every time
+	if answer, _ := matchPresetAnswer("Which ticket is this for?", answers); answer != "TEAM-2" {
+		t.Errorf("matchPresetAnswer() = %q, want TEAM-2", answer)
+	}
+}
+
+func TestLoadAnswersFileInvalid(t *testing.T) {
+	for _, content := range []string{`["ticket"]`, `{"ticket": 1}`, `{" ": "x"}`, `{"ticket": "x"`} {
+		dir := t.TempDir()
+		writeFile(t, dir, "answers.json", content)
+		if _, err := loadAnswersFile(filepath.Join(dir, "answers.json")); err == nil {
+			t.Errorf("loadAnswersFile(%s) succeeded, want an error", content)
+		}
+	}
+}
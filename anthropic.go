@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultAnthropicBaseURL is the Anthropic API base URL used when base_url is not configured
+const defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+
+// anthropicVersion is the Anthropic API version sent with every request
+const anthropicVersion = "2023-06-01"
+
+// AnthropicRequest is the request body of the Anthropic Messages API
+type AnthropicRequest struct {
+	Model       string        `json:"model"`
+	System      string        `json:"system,omitempty"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature float64       `json:"temperature"`
+}
+
+// AnthropicResponse is the response body of the Anthropic Messages API
+type AnthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct{}
+
+// newAnthropicRequest builds a Messages API request. System messages are moved to the
+// top-level system field, which is where Anthropic expects the system prompt.
+func newAnthropicRequest(messages []ChatMessage, config LLMConfig) AnthropicRequest {
+	var system []string
+	var conversation []ChatMessage
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		conversation = append(conversation, message)
+	}
+
+	// Anthropic accepts temperatures between 0 and 1
+	temperature := config.Temperature
+	if temperature > 1 {
+		Log(DEBUG, "Capping temperature %.2f to 1.0 for Anthropic", temperature)
+		temperature = 1
+	}
+
+	return AnthropicRequest{
+		Model:       config.Model,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    conversation,
+		MaxTokens:   config.MaxTokens,
+		Temperature: temperature,
+	}
+}
+
+// Chat sends the conversation to the Messages API and returns the text of the reply
+func (anthropicProvider) Chat(messages []ChatMessage, config LLMConfig) (string, error) {
+	jsonData, err := json.Marshal(newAnthropicRequest(messages, config))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL(config, "/messages"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeaders(req, config)
+
+	release := acquireRequestSlot()
+	defer release()
+
+	resp, body, err := doHTTPRequest(req, config)
+	if err != nil {
+		return "", err
+	}
+
+	var response AnthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	// Anthropic reports errors as {"type": "error", "error": {"type": ..., "message": ...}}
+	if response.Error != nil {
+		return "", &APIError{Err: fmt.Errorf("API error: %s: %s", response.Error.Type, response.Error.Message)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &APIError{Err: fmt.Errorf("API error: unexpected status %s", resp.Status)}
+	}
+
+	if err := checkFinishReason(response.StopReason, config); err != nil {
+		return "", err
+	}
+
+	var text []string
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text = append(text, block.Text)
+		}
+	}
+	if len(text) == 0 {
+		return "", fmt.Errorf("no response from API")
+	}
+
+	var usage Usage
+	if response.Usage != nil {
+		usage = Usage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+			TotalTokens:      response.Usage.InputTokens + response.Usage.OutputTokens,
+		}
+		Log(DEBUG, "Token usage: %d prompt, %d completion", usage.PromptTokens, usage.CompletionTokens)
+	}
+	recordUsage(config, usage)
+
+	return strings.Join(text, ""), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// branchTitleSeparators are the characters in branch names that stand for spaces in titles
+var branchTitleSeparators = regexp.MustCompile(`[-_.\s]+`)
+
+// branchTitle derives a PR title from a branch name, e.g. "feature/TEAM-123-add-new-thing"
+// becomes "Add new thing (TEAM-123)". If pattern is set, the branch name is rewritten with
+// it and replacement (regexp syntax, e.g. "$1") instead, and the result used as-is.
+func branchTitle(branch string, pattern string, replacement string) (string, error) {
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid branch_title_pattern: %v", err)
+		}
+		title := strings.TrimSpace(re.ReplaceAllString(branch, replacement))
+		Log(DEBUG, "Rewrote branch %s to PR title %q", branch, title)
+		return title, nil
+	}
+
+	// Drop prefixes such as feature/ or user/name/
+	name := branch[strings.LastIndex(branch, "/")+1:]
+	ticket := detectTicket(name)
+	if ticket != "" {
+		name = regexp.MustCompile(`(?i)`+regexp.QuoteMeta(ticket)).ReplaceAllString(name, " ")
+	}
+	words := strings.TrimSpace(branchTitleSeparators.ReplaceAllString(name, " "))
+
+	var title string
+	switch {
+	case words != "" && ticket != "":
+		title = fmt.Sprintf("%s (%s)", capitalize(words), ticket)
+	case words != "":
+		title = capitalize(words)
+	case ticket != "":
+		title = ticket
+	default:
+		title = branch
+	}
+	Log(DEBUG, "Derived PR title %q from branch %s", title, branch)
+	return title, nil
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// CacheEntry is a cached LLM generation stored as a JSON file in the cache directory
+type CacheEntry struct {
+	Kind      string    `json:"kind"`      // "commit" or "pr"
+	DiffHash  string    `json:"diff_hash"` // Hash of the diff or commit list the message was generated from
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Message   string    `json:"message"`
+}
+
+// cacheDir returns the directory cached generations are stored in
+func cacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get user home directory: %v", err)
+	}
+	return filepath.Join(home, ".gitscribe", "cache"), nil
+}
+
+// hashString returns the hex SHA-256 of s
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// generationCacheKey identifies a generation by its kind, model, template and input
+func generationCacheKey(kind string, model string, template string, input string) string {
+	return hashString(strings.Join([]string{kind, model, hashString(template), hashString(input)}, "\n"))
+}
+
+// loadCachedGeneration returns the cached message for key, if there is one
+func loadCachedGeneration(key string) (string, bool) {
+	dir, err := cacheDir()
+	if err != nil {
+		Log(WARN, "Cache unavailable: %v", err)
+		return "", false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		Log(DEBUG, "No cached generation for key %s", key[:12])
+		return "", false
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		Log(WARN, "Ignoring unreadable cache entry %s: %v", key[:12], err)
+		return "", false
+	}
+	Log(INFO, "Using cached generation from %s", entry.CreatedAt.Format(time.RFC3339))
+	return entry.Message, true
+}
+
+// saveCachedGeneration stores a generated message under key
+func saveCachedGeneration(key string, entry CacheEntry) {
+	dir, err := cacheDir()
+	if err != nil {
+		Log(WARN, "Cache unavailable: %v", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		Log(WARN, "Failed to create cache directory: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		Log(WARN, "Failed to encode cache entry: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, key+".json"), data, 0600); err != nil {
+		Log(WARN, "Failed to write cache entry: %v", err)
+		return
+	}
+	Log(DEBUG, "Cached generation under key %s", key[:12])
+}
+
+// cachedFile is a cache entry together with its file information
+type cachedFile struct {
+	Path  string
+	Size  int64
+	Entry CacheEntry
+}
+
+// listCachedGenerations returns all cache entries, newest first
+func listCachedGenerations() ([]cachedFile, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	var files []cachedFile
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		file := cachedFile{Path: path, Size: info.Size()}
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &file.Entry)
+		}
+		if err != nil {
+			Log(WARN, "Unreadable cache entry %s: %v", path, err)
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Entry.CreatedAt.After(files[j].Entry.CreatedAt)
+	})
+	return files, nil
+}
+
+// runCacheCommand implements the "cache" subcommand: list, clear or stats
+func runCacheCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: gs cache <list|clear|stats>")
+	}
+
+	files, err := listCachedGenerations()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(files) == 0 {
+			fmt.Println("Cache is empty.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DIFF HASH\tKIND\tMODEL\tCREATED\tSIZE")
+		for _, file := range files {
+			diffHash := file.Entry.DiffHash
+			if len(diffHash) > 12 {
+				diffHash = diffHash[:12]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", diffHash, file.Entry.Kind, file.Entry.Model,
+				file.Entry.CreatedAt.Format("2006-01-02 15:04:05"), formatBytes(file.Size))
+		}
+		w.Flush()
+	case "stats":
+		var total int64
+		for _, file := range files {
+			total += file.Size
+		}
+		dir, _ := cacheDir()
+		fmt.Printf("Cache directory: %s\n", dir)
+		fmt.Printf("Entries: %d\n", len(files))
+		fmt.Printf("Total size: %s\n", formatBytes(total))
+		if len(files) > 0 {
+			fmt.Printf("Newest: %s\n", files[0].Entry.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Oldest: %s\n", files[len(files)-1].Entry.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+	case "clear":
+		removed := 0
+		for _, file := range files {
+			if err := os.Remove(file.Path); err != nil {
+				Log(WARN, "Failed to remove cache entry %s: %v", file.Path, err)
+				continue
+			}
+			removed++
+		}
+		fmt.Printf("Removed %d cached entries.\n", removed)
+	default:
+		return fmt.Errorf("unknown cache operation %q (expected list, clear or stats)", args[0])
+	}
+	return nil
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
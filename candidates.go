@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// generateCandidates runs generate n times, at most config.MaxConcurrentRequests at a time.
+// The first candidate uses the configured temperature and each further one is generated
+// hotter, as on regeneration, so the candidates differ. Failed generations are dropped;
+// an error is returned only if none succeeded.
+func generateCandidates(n int, config Config, generate func(Config) (string, error)) ([]string, error) {
+	Log(INFO, "Generating %d candidate messages (max %d at a time)", n, config.MaxConcurrentRequests)
+
+	// A cached message would make every candidate the same, the prompt can't be edited
+	// once per candidate, and concurrent responses can't share stdout
+	config.LLM.EnableCache = false
+	config.LLM.EditPrompt = false
+	config.LLM.StreamOutput = false
+	config.LLM.disableQuestions()
+
+	messages := make([]string, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, config.MaxConcurrentRequests)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			candidateConfig := config
+			if i > 0 {
+				candidateConfig.LLM.Temperature = regenerationTemperature(config.LLM, i)
+			}
+			messages[i], errs[i] = generate(candidateConfig)
+		}(i)
+	}
+	wg.Wait()
+
+	var candidates []string
+	for i, message := range messages {
+		if errs[i] != nil {
+			Log(WARN, "Candidate %d failed: %v", i+1, errs[i])
+			continue
+		}
+		candidates = append(candidates, message)
+	}
+	if len(candidates) == 0 {
+		return nil, errs[0]
+	}
+	return candidates, nil
+}
+
+// chooseCandidate prints the candidates as a numbered list and asks which one to use,
+// asking again until a valid number is entered
+func chooseCandidate(candidates []string) (string, error) {
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	for i, candidate := range candidates {
+		fmt.Printf("\n=== Candidate %d ===\n%s\n", i+1, candidate)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\nUse which candidate? [1-%d]: ", len(candidates))
+		answer, err := reader.ReadString('\n')
+		n, convErr := strconv.Atoi(strings.TrimSpace(answer))
+		if convErr == nil && n >= 1 && n <= len(candidates) {
+			Log(INFO, "Using candidate %d of %d", n, len(candidates))
+			return candidates[n-1], nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("no candidate chosen")
+		}
+		fmt.Printf("Please enter a number between 1 and %d\n", len(candidates))
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+)
+
+// appendChangelogTrailer asks the LLM for a changelog entry and appends it as a "Changelog:" trailer.
+// Commits without user-facing impact get no trailer.
+func appendChangelogTrailer(message string, config LLMConfig) string {
+	entry, err := GenerateChangelogEntry(message, config)
+	if err != nil {
+		Log(WARN, "Failed to generate changelog entry: %v", err)
+		return message
+	}
+	entry = strings.TrimPrefix(entry, "Changelog:")
+	entry = strings.TrimSpace(strings.TrimLeft(entry, "-* "))
+	if entry == "" || strings.EqualFold(strings.Trim(entry, "."), "none") {
+		Log(DEBUG, "No user-facing impact, skipping the changelog trailer")
+		return message
+	}
+	return appendTrailer(message, "Changelog: "+entry)
+}
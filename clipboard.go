@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand returns the command that copies its stdin to the system clipboard
+func clipboardCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	case "windows":
+		return exec.Command("clip"), nil
+	}
+
+	// Prefer the tool for the running display server, but use whichever is installed
+	candidates := [][]string{{"xclip", "-selection", "clipboard"}, {"wl-copy"}}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates[0], candidates[1] = candidates[1], candidates[0]
+	}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err == nil {
+			return exec.Command(candidate[0], candidate[1:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("no clipboard command found (install xclip or wl-copy)")
+}
+
+// copyToClipboard copies text to the system clipboard
+func copyToClipboard(text string) error {
+	cmd, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	Log(DEBUG, "Copying %d bytes to the clipboard with %s", len(text), cmd.Path)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", cmd.Path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// copyMessage copies the final message to the clipboard. A failure is reported but doesn't
+// stop the commit or PR.
+func copyMessage(message string) {
+	if err := copyToClipboard(strings.TrimSpace(message) + "\n"); err != nil {
+		Log(WARN, "Failed to copy the message to the clipboard: %v", err)
+		fmt.Println("Could not copy the message to the clipboard:", err)
+		return
+	}
+	fmt.Println("Message copied to the clipboard.")
+}
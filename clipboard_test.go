@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// stubClipboard installs a fake clipboard command that saves its arguments and stdin in dir
+func stubClipboard(t *testing.T, name string, dir string) {
+	t.Helper()
+	stubCommand(t, name, `echo "`+name+` $*" > "`+filepath.Join(dir, "args")+`"; cat > "`+filepath.Join(dir, "clipboard")+`"`)
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("clipboard commands are only looked up on PATH on Linux")
+	}
+	tests := []struct {
+		wayland string
+		want    string
+	}{
+		{"", "xclip -selection clipboard"},
+		{"wayland-0", "wl-copy"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		t.Setenv("WAYLAND_DISPLAY", tt.wayland)
+		stubClipboard(t, "xclip", dir)
+		stubClipboard(t, "wl-copy", dir)
+
+		if err := copyToClipboard("Add retries\n"); err != nil {
+			t.Fatalf("copyToClipboard() error: %v", err)
+		}
+		args, _ := os.ReadFile(filepath.Join(dir, "args"))
+		if got := strings.TrimSpace(string(args)); got != tt.want {
+			t.Errorf("with WAYLAND_DISPLAY=%q ran %q, want %q", tt.wayland, got, tt.want)
+		}
+		if copied, _ := os.ReadFile(filepath.Join(dir, "clipboard")); string(copied) != "Add retries\n" {
+			t.Errorf("copied %q, want %q", copied, "Add retries\n")
+		}
+	}
+}
+
+func TestCopyToClipboardErrors(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("clipboard commands are only looked up on PATH on Linux")
+	}
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if err := copyToClipboard("x"); err == nil || !strings.Contains(err.Error(), "no clipboard command found") {
+		t.Errorf("copyToClipboard() error = %v, want no clipboard command found", err)
+	}
+
+	// PATH now only has the stub, so it can only use shell builtins
+	stubCommand(t, "xclip", `echo "Can't open display" >&2; exit 1`)
+	if err := copyToClipboard("x"); err == nil || !strings.Contains(err.Error(), "Can't open display") {
+		t.Errorf("copyToClipboard() error = %v, want the command's output", err)
+	}
+}
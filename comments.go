@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+)
+
+// defaultCommentPrefixes are the line prefixes treated as template comments when comment_prefixes isn't set
+var defaultCommentPrefixes = []string{"#"}
+
+// stripTemplateComments removes template guidance from a message: <!-- --> comments and
+// lines starting with one of the comment prefixes. It doesn't rely on the model having
+// followed the instruction to leave them out.
+func stripTemplateComments(message string, prefixes []string) string {
+	message = htmlCommentPattern.ReplaceAllString(message, "")
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if isCommentLine(line, prefixes) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// isCommentLine reports whether a line starts with one of the comment prefixes
+func isCommentLine(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetCommitMessagesSinceMergeBase(t *testing.T) {
+	dir := newTestRepo(t)
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "a.txt", "a\n", "Add a")
+	commitFile(t, dir, "b.txt", "b\n", "Add b")
+	// The target moves on after the branch forked; its new commits aren't the branch's
+	runGit(t, dir, "checkout", "-q", "main")
+	commitFile(t, dir, "later.txt", "later\n", "Later change on main")
+	runGit(t, dir, "checkout", "-q", "feature")
+
+	got, err := getCommitMessages("", "main", false)
+	if err != nil {
+		t.Fatalf("getCommitMessages() error: %v", err)
+	}
+	if want := []string{"Add a", "Add b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getCommitMessages() = %q, want %q", got, want)
+	}
+}
+
+func TestGetCommitMessagesPrefersRemoteTarget(t *testing.T) {
+	dir := newTestRepo(t)
+	// origin/main has a commit the stale local main doesn't, and the branch forked from it
+	runGit(t, dir, "checkout", "-q", "-b", "upstream")
+	commitFile(t, dir, "upstream.txt", "upstream\n", "Upstream change")
+	runGit(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "a.txt", "a\n", "Add a")
+
+	got, err := getCommitMessages("", "main", false)
+	if err != nil {
+		t.Fatalf("getCommitMessages() error: %v", err)
+	}
+	if want := []string{"Add a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getCommitMessages() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeBaseError(t *testing.T) {
+	newTestRepo(t)
+	_, err := mergeBase("", "origin/missing", "main")
+	if exitCode(err) != exitGitError {
+		t.Errorf("mergeBase() error = %v, want a git error", err)
+	}
+}
+
+func TestParseFullCommitLog(t *testing.T) {
+	output := commitMarker + "\nAdd retries\n\nRetry failed requests.\n\n- up to 3 times\n\n" +
+		commitMarker + "\r\nFix typo\r\n\r\n" +
+		commitMarker + "\n\n" +
+		commitMarker + "\nUpdate docs\n\n--- not a marker ---\n"
+	want := []string{
+		"Add retries\n\nRetry failed requests.\n\n- up to 3 times",
+		"Fix typo",
+		"Update docs\n\n--- not a marker ---",
+	}
+	if got := parseFullCommitLog(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFullCommitLog() = %q, want %q", got, want)
+	}
+	if got := parseFullCommitLog(""); len(got) != 0 {
+		t.Errorf("parseFullCommitLog(\"\") = %q, want none", got)
+	}
+}
+
+func TestGetCommitMessagesFull(t *testing.T) {
+	dir := newTestRepo(t)
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "a.txt", "a\n", "Add a\n\nThe a file explains things.")
+	commitFile(t, dir, "b.txt", "b\n", "Add b")
+
+	got, err := getCommitMessages("", "main", true)
+	if err != nil {
+		t.Fatalf("getCommitMessages() error: %v", err)
+	}
+	if want := []string{"Add a\n\nThe a file explains things.", "Add b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getCommitMessages() = %q, want %q", got, want)
+	}
+	if joined, want := joinCommitMessages(got), "Add a\n\nThe a file explains things.\n\n---\n\nAdd b"; joined != want {
+		t.Errorf("joinCommitMessages() = %q, want %q", joined, want)
+	}
+	if joined := joinCommitMessages([]string{"Add a", "Add b"}); joined != "Add a\nAdd b" {
+		t.Errorf("joinCommitMessages() of subjects = %q, want one per line", joined)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ModelComparison holds the message one model generated and what it cost
+type ModelComparison struct {
+	Model    string
+	Message  string
+	Usage    Usage
+	Duration time.Duration
+	Err      error
+}
+
+// compareModels runs generate once per model, at most config.MaxConcurrentRequests at a time.
+// Results are returned in the same order as models.
+func compareModels(models []string, config Config, generate func(Config) (string, error)) []ModelComparison {
+	Log(INFO, "Comparing %d models (max %d at a time)", len(models), config.MaxConcurrentRequests)
+
+	// Prompts on stdin and stdout can't be shared between concurrent generations, and a
+	// cached message would hide the token usage being compared
+	config.LLM.disableQuestions()
+	config.LLM.EditPrompt = false
+	config.LLM.StreamOutput = false
+	config.LLM.EnableCache = false
+
+	results := make([]ModelComparison, len(models))
+	sem := make(chan struct{}, config.MaxConcurrentRequests)
+	var wg sync.WaitGroup
+
+	for i, model := range models {
+		results[i].Model = model
+
+		wg.Add(1)
+		go func(i int, model string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Usage is collected per generation, as a model may be listed more than once
+			modelConfig := config
+			modelConfig.LLM.Model = model
+			modelConfig.LLM.UsageTotal = &results[i].Usage
+			start := time.Now()
+			results[i].Message, results[i].Err = generate(modelConfig)
+			results[i].Duration = time.Since(start)
+		}(i, model)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// parseModelList splits the comma-separated -compare-models value, rejecting empty entries
+func parseModelList(value string) ([]string, error) {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			return nil, fmt.Errorf("empty model name in -compare-models %q", value)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// printModelComparisons prints a table of token usage and cost per model, followed by each message
+func printModelComparisons(results []ModelComparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tPROMPT\tCOMPLETION\tCOST\tTIME\tSTATUS")
+	for _, result := range results {
+		cost := "n/a"
+		if usd, ok := estimateCost(result.Model, result.Usage); ok {
+			cost = fmt.Sprintf("$%.4f", usd)
+		}
+		status := "ok"
+		if result.Err != nil {
+			status = "error: " + strings.SplitN(result.Err.Error(), "\n", 2)[0]
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1fs\t%s\n", result.Model, result.Usage.PromptTokens,
+			result.Usage.CompletionTokens, cost, result.Duration.Seconds(), status)
+	}
+	w.Flush()
+
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		fmt.Printf("\n=== %s ===\n", result.Model)
+		fmt.Println(result.Message)
+	}
+}
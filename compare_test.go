@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCompareModelsUsagePerGeneration(t *testing.T) {
+	config := Config{MaxConcurrentRequests: 1}
+	results := compareModels([]string{"model-a", "model-a", "model-b"}, config, func(c Config) (string, error) {
+		recordUsage(c.LLM, Usage{PromptTokens: 100, CompletionTokens: 10})
+		return "message", nil
+	})
+	for _, result := range results {
+		if result.Usage.PromptTokens != 100 || result.Usage.CompletionTokens != 10 {
+			t.Errorf("%s: usage = %+v, want that of one call", result.Model, result.Usage)
+		}
+	}
+}
+
+func TestParseModelList(t *testing.T) {
+	models, err := parseModelList(" gpt-4o , gpt-4o-mini")
+	if err != nil || len(models) != 2 || models[0] != "gpt-4o" || models[1] != "gpt-4o-mini" {
+		t.Errorf("parseModelList() = %q, %v", models, err)
+	}
+	for _, value := range []string{"gpt-4o,", "a,,b", " "} {
+		if _, err := parseModelList(value); err == nil {
+			t.Errorf("parseModelList(%q) succeeded, want an error for the empty entry", value)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// defaultMaxConcurrentRequests bounds parallel LLM requests when max_concurrent_requests isn't set
+const defaultMaxConcurrentRequests = 3
+
+// requestSlots is a semaphore shared by everything that calls the LLM API concurrently
+var requestSlots = make(chan struct{}, defaultMaxConcurrentRequests)
+
+// setMaxConcurrentRequests resizes the request semaphore. Call it before starting any parallel work.
+func setMaxConcurrentRequests(n int) {
+	Log(DEBUG, "Limiting concurrent LLM requests to %d", n)
+	requestSlots = make(chan struct{}, n)
+}
+
+// acquireRequestSlot blocks until a request slot is free and returns the function that releases it
+func acquireRequestSlot() func() {
+	slots := requestSlots
+	slots <- struct{}{}
+	return func() { <-slots }
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ConfigNotFoundError is returned when no config file exists in any searched location
+type ConfigNotFoundError struct {
+	Searched []string
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	return fmt.Sprintf("no config file found (searched: %s)", strings.Join(e.Searched, ", "))
+}
+
+// ConfigInvalidError is returned when a config file exists but cannot be loaded
+type ConfigInvalidError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigInvalidError) Error() string {
+	return fmt.Sprintf("config at %s is invalid: %v", e.Path, e.Err)
+}
+
+func (e *ConfigInvalidError) Unwrap() error {
+	return e.Err
+}
+
+// ensureConfig resolves and loads the config, returning it along with the path it came from.
+// Custom paths are used as-is without falling back, and several are merged in order (see
+// loadMergedConfig); otherwise the first existing file in configSearchPaths wins. An existing
+// but broken file is reported rather than skipped. It has no side effects, so it is safe to
+// call repeatedly.
+//
+// Callers layer command-line flags over the loaded settings where they apply, e.g. -target
+// over default_target_branch (see resolveTargetBranch for the full precedence).
+func ensureConfig(customPaths []string) (Config, string, error) {
+	Log(INFO, "Loading config from prioritized locations")
+
+	if len(customPaths) > 1 {
+		var paths []string
+		for _, path := range customPaths {
+			path = expandPath(path)
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				return Config{}, path, &ConfigNotFoundError{Searched: []string{path}}
+			}
+			paths = append(paths, path)
+		}
+		config, err := loadMergedConfig(paths)
+		return config, strings.Join(paths, ", "), err
+	}
+
+	locations := configSearchPaths()
+	if len(customPaths) == 1 {
+		Log(DEBUG, "Custom config path provided: %s", customPaths[0])
+		locations = []string{expandPath(customPaths[0])}
+	}
+
+	Log(DEBUG, "Trying %d potential config locations", len(locations))
+	for _, location := range locations {
+		Log(DEBUG, "Trying config location: %s", location)
+		if _, err := os.Stat(location); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				Log(DEBUG, "No config at %s", location)
+				continue
+			}
+			return Config{}, location, &ConfigInvalidError{Path: location, Err: err}
+		}
+
+		config, err := loadConfig(location)
+		if err != nil {
+			Log(ERROR, "Failed to load config from %s: %v", location, err)
+			return Config{}, location, &ConfigInvalidError{Path: location, Err: err}
+		}
+		Log(INFO, "Successfully loaded config from: %s", location)
+		return config, location, nil
+	}
+
+	Log(ERROR, "Could not find config file in any standard location")
+	return Config{}, "", &ConfigNotFoundError{Searched: locations}
+}
+
+// loadMergedConfig loads several config files, each later file overriding the earlier ones.
+// Objects (such as "llm") are merged key by key; scalars and arrays are replaced as a whole.
+// Defaults are applied once, to the merged result.
+func loadMergedConfig(paths []string) (Config, error) {
+	merged := map[string]interface{}{}
+	for _, path := range paths {
+		Log(INFO, "Loading config layer from: %s", path)
+		data, err := readConfigData(path)
+		if err != nil {
+			return Config{}, &ConfigInvalidError{Path: path, Err: err}
+		}
+		// Check each file on its own so unknown keys are reported against the right file
+		if err := decodeConfig(data, &Config{}); err != nil {
+			return Config{}, &ConfigInvalidError{Path: path, Err: err}
+		}
+		var layer map[string]interface{}
+		if err := json.Unmarshal(data, &layer); err != nil {
+			return Config{}, &ConfigInvalidError{Path: path, Err: err}
+		}
+		mergeConfigMaps(merged, layer)
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to merge config files: %v", err)
+	}
+	config, err := parseConfig(data)
+	if err != nil {
+		return Config{}, &ConfigInvalidError{Path: strings.Join(paths, " + "), Err: err}
+	}
+	return config, nil
+}
+
+// mergeConfigMaps deep-merges src into dst: nested objects are merged recursively,
+// any other value (including arrays) replaces the one in dst
+func mergeConfigMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeConfigMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// configPathList collects the values of the repeatable -config flag
+type configPathList []string
+
+func (l *configPathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *configPathList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// stringListFlag collects the values of a repeatable flag. Each value may also be a
+// comma-separated list.
+type stringListFlag []string
+
+func (l *stringListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringListFlag) Set(value string) error {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return fmt.Errorf("empty value in %q", value)
+		}
+		items = append(items, item)
+	}
+	*l = append(*l, items...)
+	return nil
+}
+
+// unknownFieldPattern extracts the key name from encoding/json's unknown field error
+var unknownFieldPattern = regexp.MustCompile(`unknown field "([^"]+)"`)
+
+// knownConfigKeys returns the JSON keys accepted in the config file, including nested LLM keys
+func knownConfigKeys() []string {
+	var keys []string
+	for _, t := range []reflect.Type{reflect.TypeOf(Config{}), reflect.TypeOf(LLMConfig{})} {
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				keys = append(keys, name)
+			}
+		}
+	}
+	return keys
+}
+
+// suggestConfigKey returns the known config key closest to an unknown one,
+// or an empty string if none is close enough to be a likely typo
+func suggestConfigKey(unknown string) string {
+	best := ""
+	bestDistance := len(unknown)/3 + 1
+	for _, key := range knownConfigKeys() {
+		distance := levenshtein(strings.ToLower(unknown), key)
+		if distance <= bestDistance && (best == "" || distance < levenshtein(strings.ToLower(unknown), best)) {
+			best = key
+		}
+	}
+	return best
+}
+
+// configKeyHint returns a "did you mean" hint for an unknown field error, if one applies
+func configKeyHint(err error) string {
+	match := unknownFieldPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return ""
+	}
+	if suggestion := suggestConfigKey(match[1]); suggestion != "" {
+		return " (did you mean " + suggestion + "?)"
+	}
+	return ""
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
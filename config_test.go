@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const jsonConfig = `{
+	"llm": {"provider": "anthropic", "model": "claude-test", "temperature": 0.3, "max_tokens": 500},
+	"max_diff_tokens": 4000,
+	"exclude_paths": ["go.sum", "vendor/"],
+	"allowed_labels": ["bug", "enhancement"],
+	"model_prices": {"claude-test": {"input": 3, "output": 15}}
+}`
+
+const yamlConfig = `llm:
+  provider: anthropic
+  model: claude-test
+  temperature: 0.3
+  max_tokens: 500
+max_diff_tokens: 4000
+exclude_paths:
+  - go.sum
+  - vendor/
+allowed_labels: [bug, enhancement]
+model_prices:
+  claude-test:
+    input: 3
+    output: 15
+`
+
+func TestLoadConfigYAMLMatchesJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.json", jsonConfig)
+	writeFile(t, dir, "config.yaml", yamlConfig)
+
+	fromJSON, err := loadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("loadConfig(json) error: %v", err)
+	}
+	fromYAML, err := loadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig(yaml) error: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("YAML config = %+v, want the same as JSON %+v", fromYAML, fromJSON)
+	}
+	if fromYAML.LLM.Model != "claude-test" || fromYAML.MaxDiffTokens != 4000 || len(fromYAML.ExcludePaths) != 2 {
+		t.Errorf("YAML config = %+v, want the values from the file", fromYAML)
+	}
+}
+
+func TestLoadConfigYAMLRejectsUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yml", "max_dif_tokens: 4000\n")
+
+	_, err := loadConfig(filepath.Join(dir, "config.yml"))
+	if err == nil || !strings.Contains(err.Error(), "max_dif_tokens") {
+		t.Errorf("loadConfig() error = %v, want the unknown key reported", err)
+	}
+}
+
+func TestLoadConfigEmptyYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "# nothing set\n")
+
+	config, err := loadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if config.LLM.Model == "" {
+		t.Errorf("empty YAML config has no default model")
+	}
+}
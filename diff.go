@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// diffFiles returns the paths of the files touched by a unified git diff, in diff order
+func diffFiles(diff string) []string {
+	var files []string
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "diff --git ") {
+			continue
+		}
+		// Format: diff --git a/<path> b/<path>
+		idx := strings.LastIndex(line, " b/")
+		if idx == -1 {
+			continue
+		}
+		files = append(files, line[idx+3:])
+	}
+	return files
+}
+
+// pathMatches reports whether file is, or lies under, the path given by pattern, as used for
+// exclude_paths and protected_paths. Patterns are relative to the repository root and a trailing
+// slash is optional. Globs are matched against the whole path, except that a pattern without a
+// slash also matches the file name in any directory, e.g. "go.sum" or "*.pem".
+func pathMatches(file string, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+	if pattern == "" {
+		return false
+	}
+	if file == pattern || strings.HasPrefix(file, pattern+"/") {
+		return true
+	}
+	if matched, _ := path.Match(pattern, file); matched {
+		return true
+	}
+	if strings.Contains(pattern, "/") {
+		return false
+	}
+	matched, _ := path.Match(pattern, path.Base(file))
+	return matched
+}
+
+// splitDiffSections splits a unified git diff into per-file sections, each starting with "diff --git"
+func splitDiffSections(diff string) []string {
+	var sections []string
+	var current []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") && len(current) > 0 {
+			sections = append(sections, strings.Join(current, "\n"))
+			current = nil
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		sections = append(sections, strings.Join(current, "\n"))
+	}
+	return sections
+}
+
+// diffFilter removes content from a diff before it is sent to the LLM
+type diffFilter struct {
+	Name  string
+	Apply func(diff string) string
+}
+
+// binaryFilter drops the sections of binary files, which carry nothing the LLM can read
+var binaryFilter = diffFilter{
+	Name: "binary files",
+	Apply: func(diff string) string {
+		var kept []string
+		for _, section := range splitDiffSections(diff) {
+			if strings.Contains(section, "\nBinary files ") || strings.Contains(section, "\nGIT binary patch") {
+				Log(DEBUG, "Dropping binary file from diff: %v", diffFiles(section))
+				continue
+			}
+			kept = append(kept, section)
+		}
+		return strings.Join(kept, "\n")
+	},
+}
+
+// excludePathsFilter drops the sections of files matching one of the patterns. The files are
+// still committed; their changes just aren't sent to the LLM. If every file is excluded, e.g.
+// for a lockfile-only update, a summary of the excluded changes is sent instead.
+func excludePathsFilter(patterns []string) diffFilter {
+	return diffFilter{
+		Name: "exclude_paths",
+		Apply: func(diff string) string {
+			var kept, dropped []string
+			for _, section := range splitDiffSections(diff) {
+				if excluded := sectionExcluded(section, patterns); excluded != "" {
+					Log(DEBUG, "Dropping %s from diff (matches exclude_paths)", excluded)
+					dropped = append(dropped, section)
+					continue
+				}
+				kept = append(kept, section)
+			}
+			if len(kept) == 0 && len(dropped) > 0 {
+				Log(INFO, "All changed files match exclude_paths, describing them by their line counts")
+				return excludedChangesSummary(dropped)
+			}
+			return strings.Join(kept, "\n")
+		},
+	}
+}
+
+// excludedChangesSummary lists the files of the excluded diff sections with how many lines were
+// added and removed, in place of their diffs
+func excludedChangesSummary(sections []string) string {
+	var sb strings.Builder
+	sb.WriteString("Only files whose diffs are left out changed:\n")
+	for _, section := range sections {
+		added, removed := 0, 0
+		for _, line := range strings.Split(section, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			case strings.HasPrefix(line, "+"):
+				added++
+			case strings.HasPrefix(line, "-"):
+				removed++
+			}
+		}
+		if files := diffFiles(section); len(files) > 0 {
+			sb.WriteString(fmt.Sprintf("%s: %d lines added, %d removed\n", files[0], added, removed))
+		}
+	}
+	return sb.String()
+}
+
+// sectionExcluded returns the file of a diff section if it matches one of the patterns
+func sectionExcluded(section string, patterns []string) string {
+	for _, file := range diffFiles(section) {
+		for _, pattern := range patterns {
+			if pathMatches(file, pattern) {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// commitDiffFilters returns the filters applied to the staged diff
+func commitDiffFilters(config Config) []diffFilter {
+	filters := []diffFilter{binaryFilter}
+	if len(config.ExcludePaths) > 0 {
+		filters = append(filters, excludePathsFilter(config.ExcludePaths))
+	}
+	if config.MaxDiffTokens > 0 {
+		// Runs last so the budget goes to what is actually sent
+		filters = append(filters, diffFilter{
+			Name:  "max_diff_tokens",
+			Apply: func(diff string) string { return truncateDiff(diff, config.MaxDiffTokens) },
+		})
+	}
+	return filters
+}
+
+// filterDiff applies the filters in order. It returns an error naming the filter responsible
+// if the filters remove everything from a diff that had content.
+func filterDiff(diff string, filters []diffFilter) (string, error) {
+	for _, filter := range filters {
+		before := diff
+		diff = filter.Apply(diff)
+		Log(DEBUG, "Diff filter %q: %d -> %d bytes", filter.Name, len(before), len(diff))
+		if strings.TrimSpace(before) != "" && strings.TrimSpace(diff) == "" {
+			Log(ERROR, "All staged changes were excluded by the %s filter", filter.Name)
+			return "", fmt.Errorf("all staged changes were excluded by filters (the %s filter removed everything that was left)", filter.Name)
+		}
+	}
+	return diff, nil
+}
+
+// commonDir returns the longest directory path shared by all files, or an empty string
+// if they only share the repository root
+func commonDir(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	common := strings.Split(filepath.ToSlash(filepath.Dir(files[0])), "/")
+	for _, file := range files[1:] {
+		parts := strings.Split(filepath.ToSlash(filepath.Dir(file)), "/")
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	dir := strings.Join(common, "/")
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// suggestedScope derives the first line scope from the changed files' common directory.
+// For the default format this is "<top-level directory> <common directory>", e.g.
+// "go ingester_worker"; for Conventional Commits it is just the common directory name.
+func suggestedScope(files []string, style string) string {
+	dir := commonDir(files)
+	if dir == "" {
+		return ""
+	}
+	parts := strings.Split(dir, "/")
+	leaf := parts[len(parts)-1]
+	if style == StyleConventional || len(parts) == 1 {
+		return leaf
+	}
+	return parts[0] + " " + leaf
+}
+
+// classifyChange makes a heuristic guess at the type of change in a diff.
+// It returns one of docs, test, ci, build, feat, fix or chore.
+func classifyChange(diff string) string {
+	files := diffFiles(diff)
+	Log(DEBUG, "Classifying change across %d files", len(files))
+
+	if len(files) > 0 {
+		switch {
+		case allFiles(files, isDocsFile):
+			return "docs"
+		case allFiles(files, isTestFile):
+			return "test"
+		case allFiles(files, isCIFile):
+			return "ci"
+		case allFiles(files, isBuildFile):
+			return "build"
+		}
+	}
+
+	if strings.Contains(diff, "\nnew file mode ") {
+		return "feat"
+	}
+
+	// Look for fix-related wording in the added lines
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "+") || strings.HasPrefix(line, "+++") {
+			continue
+		}
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "fix") || strings.Contains(lower, "bug") {
+			return "fix"
+		}
+	}
+
+	return "chore"
+}
+
+// allFiles reports whether every file satisfies match
+func allFiles(files []string, match func(string) bool) bool {
+	for _, file := range files {
+		if !match(file) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDocsFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".rst" || ext == ".txt" || strings.HasPrefix(path, "docs/")
+}
+
+func isTestFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") ||
+		strings.Contains(base, ".spec.") || strings.HasPrefix(path, "test/") || strings.HasPrefix(path, "tests/")
+}
+
+func isCIFile(path string) bool {
+	return strings.HasPrefix(path, ".github/workflows/") || path == ".gitlab-ci.yml" || strings.HasPrefix(path, ".circleci/")
+}
+
+func isBuildFile(path string) bool {
+	switch filepath.Base(path) {
+	case "go.mod", "go.sum", "Makefile", "Dockerfile", "package.json", "package-lock.json", "build.sh":
+		return true
+	}
+	return false
+}
+
+// languageExtensions maps file extensions to the language names used in the prompt
+var languageExtensions = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".ts": "TypeScript",
+	".tsx": "TypeScript", ".java": "Java", ".kt": "Kotlin", ".rb": "Ruby", ".rs": "Rust",
+	".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".cs": "C#",
+	".swift": "Swift", ".php": "PHP", ".scala": "Scala", ".sh": "shell", ".bash": "shell",
+	".sql": "SQL", ".yaml": "YAML", ".yml": "YAML", ".json": "JSON", ".toml": "TOML",
+	".tf": "Terraform", ".html": "HTML", ".css": "CSS", ".scss": "SCSS", ".md": "Markdown",
+	".proto": "Protocol Buffers",
+}
+
+// primaryLanguage returns the language of the majority of changed lines in the diff,
+// or "" if no single known language accounts for more than half of them
+func primaryLanguage(diff string) string {
+	linesByLanguage := map[string]int{}
+	total := 0
+	for _, section := range splitDiffSections(diff) {
+		files := diffFiles(section)
+		if len(files) == 0 {
+			continue
+		}
+		changed := len(changedLines(section))
+		total += changed
+		if language, ok := languageExtensions[strings.ToLower(filepath.Ext(files[0]))]; ok {
+			linesByLanguage[language] += changed
+		}
+	}
+
+	for language, lines := range linesByLanguage {
+		if lines*2 > total {
+			return language
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPathMatches(t *testing.T) {
+	tests := []struct {
+		file    string
+		pattern string
+		want    bool
+	}{
+		{"certs/key.pem", "*.pem", true},
+		{"key.pem", "*.pem", true},
+		{"secrets/a/b.txt", "secrets/", true},
+		{"secrets.txt", "secrets/", false},
+		{"a/go.sum", "go.sum", true},
+		{"a/b/c.pem", "b/*.pem", false},
+		{"b/c.pem", "b/*.pem", true},
+		{"README.md", "*.pem", false},
+	}
+	for _, tt := range tests {
+		if got := pathMatches(tt.file, tt.pattern); got != tt.want {
+			t.Errorf("pathMatches(%q, %q) = %v, want %v", tt.file, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFilterDiffOnlyExcludedFiles(t *testing.T) {
+	diff := `diff --git a/web/package-lock.json b/web/package-lock.json
+--- a/web/package-lock.json
++++ b/web/package-lock.json
+@@ -1,3 +1,3 @@
+ {
+-  "version": "1.0.0",
++  "version": "1.0.1",
++  "lockfileVersion": 3,
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1 @@
+-example.com/a v1.0.0 h1:old=
++example.com/a v1.1.0 h1:new=
+`
+	got, err := filterDiff(diff, commitDiffFilters(Config{ExcludePaths: []string{"package-lock.json", "go.sum"}}))
+	if err != nil {
+		t.Fatalf("filterDiff() error = %v", err)
+	}
+	want := "Only files whose diffs are left out changed:\n" +
+		"web/package-lock.json: 2 lines added, 1 removed\n" +
+		"go.sum: 1 lines added, 1 removed\n"
+	if got != want {
+		t.Errorf("filterDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestFilterDiffExcludedPaths(t *testing.T) {
+	mainDiff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package old
++package main`
+	diff := mainDiff + `
+diff --git a/vendor/lib/lib.go b/vendor/lib/lib.go
+--- a/vendor/lib/lib.go
++++ b/vendor/lib/lib.go
+@@ -1 +1 @@
+-package lib
++package lib // v2
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1 @@
+-example.com/a v1.0.0 h1:old=
++example.com/a v1.1.0 h1:new=`
+
+	got, err := filterDiff(diff, commitDiffFilters(Config{ExcludePaths: []string{"vendor/", "go.sum"}}))
+	if err != nil {
+		t.Fatalf("filterDiff() error = %v", err)
+	}
+	if got != mainDiff {
+		t.Errorf("filterDiff() = %q, want only the main.go diff %q", got, mainDiff)
+	}
+	if strings.Contains(got, "left out") {
+		t.Errorf("filterDiff() = %q, want no summary when some files are kept", got)
+	}
+}
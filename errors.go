@@ -0,0 +1,49 @@
+package main
+
+import "errors"
+
+// Errors that callers can tell apart with errors.Is. They are wrapped with details where
+// they occur, and main maps them to exit codes (see exitCode).
+var (
+	ErrNoStagedChanges = errors.New("no changes staged")
+	ErrAPIKeyMissing   = errors.New("API key not found")
+	ErrRateLimited     = errors.New("rate limited by the API")
+	ErrNotGitRepo      = errors.New("not a git repository")
+	ErrEditorNotFound  = errors.New("editor could not be started")
+)
+
+// APIError marks a failure to get an answer from the LLM API: the request failed or timed
+// out, or the API returned an error. Its message can quote the API's response, which may echo
+// the API key, so registered secrets are masked in it as they are in the log.
+type APIError struct {
+	Err error
+}
+
+func (e *APIError) Error() string {
+	return redactSecrets(e.Err.Error())
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// GitError marks a failed git command
+type GitError struct {
+	Err error
+}
+
+func (e *GitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// ensureGitRepo checks that git commands run inside a git repository
+func ensureGitRepo() error {
+	if err := gitCommand("", "rev-parse", "--git-dir").Run(); err != nil {
+		return &GitError{Err: ErrNotGitRepo}
+	}
+	return nil
+}
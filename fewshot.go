@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Section headers of the few-shot examples file, in the style of the -edit-prompt file:
+//
+//	=== diff ===
+//	<git diff>
+//	=== message ===
+//	<commit message for that diff>
+//
+// Any number of diff/message pairs may follow each other.
+const (
+	fewShotDiffHeader    = "=== diff ==="
+	fewShotMessageHeader = "=== message ==="
+)
+
+// Default limits on the few-shot examples included in the prompt
+const (
+	defaultFewShotMaxExamples = 3
+	defaultFewShotMaxTokens   = 2000
+)
+
+// FewShotExample is an example diff with the commit message it should get
+type FewShotExample struct {
+	Diff    string
+	Message string
+}
+
+// parseFewShotExamples parses diff/message pairs from the few-shot examples file format
+func parseFewShotExamples(text string) ([]FewShotExample, error) {
+	var examples []FewShotExample
+	var current *FewShotExample
+	var section string
+	var content []string
+
+	flush := func() {
+		value := strings.TrimSpace(strings.Join(content, "\n"))
+		switch section {
+		case fewShotDiffHeader:
+			current.Diff = value
+		case fewShotMessageHeader:
+			current.Message = value
+		}
+		content = nil
+	}
+
+	for i, line := range strings.Split(normalizeLineEndings(text), "\n") {
+		switch strings.TrimSpace(line) {
+		case fewShotDiffHeader:
+			if section != "" {
+				flush()
+			}
+			examples = append(examples, FewShotExample{})
+			current = &examples[len(examples)-1]
+			section = fewShotDiffHeader
+		case fewShotMessageHeader:
+			if section != fewShotDiffHeader {
+				return nil, fmt.Errorf("line %d: %q must follow a %q section", i+1, fewShotMessageHeader, fewShotDiffHeader)
+			}
+			flush()
+			section = fewShotMessageHeader
+		default:
+			if section == "" {
+				if strings.TrimSpace(line) != "" {
+					return nil, fmt.Errorf("line %d: expected %q before any content", i+1, fewShotDiffHeader)
+				}
+				continue
+			}
+			content = append(content, line)
+		}
+	}
+	if section != "" {
+		flush()
+	}
+
+	for i, example := range examples {
+		if example.Diff == "" || example.Message == "" {
+			return nil, fmt.Errorf("example %d needs both a diff and a message", i+1)
+		}
+	}
+	return examples, nil
+}
+
+// selectFewShotExamples keeps examples in file order, up to maxExamples and an approximate
+// budget of maxTokens. Examples that don't fit the remaining budget are skipped.
+func selectFewShotExamples(examples []FewShotExample, maxExamples int, maxTokens int) []FewShotExample {
+	var selected []FewShotExample
+	used := 0
+	for i, example := range examples {
+		if len(selected) >= maxExamples {
+			Log(DEBUG, "Using the first %d of %d few-shot examples", maxExamples, len(examples))
+			break
+		}
+		tokens := estimateTokens(example.Diff) + estimateTokens(example.Message)
+		if used+tokens > maxTokens {
+			Log(DEBUG, "Skipping few-shot example %d (~%d tokens): over the budget of %d tokens", i+1, tokens, maxTokens)
+			continue
+		}
+		selected = append(selected, example)
+		used += tokens
+	}
+	return selected
+}
+
+// loadFewShotExamples reads the few-shot examples file and selects the examples to include
+func loadFewShotExamples(path string, maxExamples int, maxTokens int) ([]FewShotExample, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read few-shot examples: %v", err)
+	}
+	examples, err := parseFewShotExamples(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid few-shot examples in %s: %v", path, err)
+	}
+	selected := selectFewShotExamples(examples, maxExamples, maxTokens)
+	if len(selected) == 0 && len(examples) > 0 {
+		Log(WARN, "None of the few-shot examples in %s fit few_shot_max_tokens (%d)", path, maxTokens)
+	}
+	Log(DEBUG, "Including %d few-shot examples from %s", len(selected), path)
+	return selected, nil
+}
+
+// fewShotMessages turns examples into earlier turns of the conversation, so the model sees
+// each diff answered with its commit message before the real diff
+func fewShotMessages(examples []FewShotExample) []ChatMessage {
+	var messages []ChatMessage
+	for _, example := range examples {
+		messages = append(messages,
+			ChatMessage{Role: "user", Content: fmt.Sprintf("Here is the git diff:\n\n%s", example.Diff)},
+			ChatMessage{Role: "assistant", Content: example.Message},
+		)
+	}
+	return messages
+}
+
+// formatFewShotExamples writes examples back in the file format, e.g. for cache keys
+func formatFewShotExamples(examples []FewShotExample) string {
+	var sb strings.Builder
+	for _, example := range examples {
+		sb.WriteString(fmt.Sprintf("%s\n%s\n%s\n%s\n", fewShotDiffHeader, example.Diff, fewShotMessageHeader, example.Message))
+	}
+	return sb.String()
+}
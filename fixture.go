@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// LLMFixture is a recorded sequence of LLM responses used for offline replay
+type LLMFixture struct {
+	Responses []string `json:"responses"`
+}
+
+var (
+	// fixtureMu guards the fixture state, since repositories may be processed concurrently
+	fixtureMu sync.Mutex
+	// replayFixture holds the loaded fixture and replayIndex the next response to return
+	replayFixture *LLMFixture
+	replayIndex   int
+	// recordFixture accumulates the responses recorded during this run
+	recordFixture LLMFixture
+)
+
+// replayResponse returns the next recorded response from the replay file
+func replayResponse(path string) (string, error) {
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+
+	if replayFixture == nil {
+		Log(INFO, "Loading LLM fixture for replay: %s", path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			Log(ERROR, "Failed to read replay file: %v", err)
+			return "", fmt.Errorf("failed to read replay file: %v", err)
+		}
+		var fixture LLMFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			Log(ERROR, "Failed to parse replay file: %v", err)
+			return "", fmt.Errorf("failed to parse replay file: %v", err)
+		}
+		replayFixture = &fixture
+	}
+
+	if replayIndex >= len(replayFixture.Responses) {
+		Log(ERROR, "Replay file has no more recorded responses")
+		return "", fmt.Errorf("replay file %s has only %d recorded responses", path, len(replayFixture.Responses))
+	}
+	response := replayFixture.Responses[replayIndex]
+	replayIndex++
+	Log(DEBUG, "Replaying recorded response %d (%d chars)", replayIndex, len(response))
+	return response, nil
+}
+
+// recordResponse appends a response to the record file, rewriting it so it is complete after every call
+func recordResponse(path string, response string) error {
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+
+	recordFixture.Responses = append(recordFixture.Responses, response)
+	data, err := json.MarshalIndent(recordFixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recorded responses: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		Log(ERROR, "Failed to write record file: %v", err)
+		return fmt.Errorf("failed to write record file: %v", err)
+	}
+	Log(DEBUG, "Recorded response %d to %s", len(recordFixture.Responses), path)
+	return nil
+}
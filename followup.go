@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// followUpWindow is how many of the most recent commits count as "recent" when looking for follow-ups
+const followUpWindow = 10
+
+// hunkHeaderPattern extracts the pre-image start line from a hunk header
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// blameHeaderPattern matches the per-line header of git blame --porcelain output
+var blameHeaderPattern = regexp.MustCompile(`^([0-9a-f]{40}) \d+ \d+`)
+
+// FollowUp describes the recent commit that introduced the lines a change modifies
+type FollowUp struct {
+	SHA     string
+	Subject string
+}
+
+// lineRange is an inclusive range of line numbers
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// removedLineRanges returns, per file, the pre-image line ranges removed or modified by the diff
+func removedLineRanges(diff string) map[string][]lineRange {
+	ranges := make(map[string][]lineRange)
+	for _, section := range splitDiffSections(diff) {
+		file := ""
+		oldLine := 0
+		for _, line := range strings.Split(section, "\n") {
+			switch {
+			case strings.HasPrefix(line, "--- "):
+				file = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+				if file == "/dev/null" {
+					file = ""
+				}
+			case strings.HasPrefix(line, "+++ "):
+			case strings.HasPrefix(line, "@@"):
+				if m := hunkHeaderPattern.FindStringSubmatch(line); m != nil {
+					oldLine, _ = strconv.Atoi(m[1])
+				}
+			case strings.HasPrefix(line, "-"):
+				if file != "" && oldLine > 0 {
+					fileRanges := ranges[file]
+					if n := len(fileRanges); n > 0 && fileRanges[n-1].End == oldLine-1 {
+						fileRanges[n-1].End = oldLine
+					} else {
+						fileRanges = append(fileRanges, lineRange{Start: oldLine, End: oldLine})
+					}
+					ranges[file] = fileRanges
+				}
+				oldLine++
+			case strings.HasPrefix(line, " "):
+				oldLine++
+			}
+		}
+	}
+	return ranges
+}
+
+// blameCommits returns the commits that last touched the given lines of a file at HEAD
+func blameCommits(file string, r lineRange) ([]string, error) {
+	output, err := gitCommand("", "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", r.Start, r.End), "HEAD", "--", file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame failed for %s: %v", file, err)
+	}
+	var shas []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := blameHeaderPattern.FindStringSubmatch(line); m != nil {
+			shas = append(shas, m[1])
+		}
+	}
+	return shas, nil
+}
+
+// detectFollowUp reports whether the diff only modifies lines introduced by a single recent commit
+func detectFollowUp(diff string) (FollowUp, bool) {
+	// Line numbers of a diff against an older base don't match a blame of HEAD
+	if stagedDiffBase != "" {
+		return FollowUp{}, false
+	}
+
+	ranges := removedLineRanges(diff)
+	if len(ranges) == 0 {
+		return FollowUp{}, false
+	}
+
+	output, err := gitCommand("", "log", fmt.Sprintf("--max-count=%d", followUpWindow), "--format=%H").Output()
+	if err != nil {
+		Log(DEBUG, "Could not read recent commits for follow-up detection: %v", err)
+		return FollowUp{}, false
+	}
+	recent := make(map[string]bool)
+	for _, sha := range strings.Fields(string(output)) {
+		recent[sha] = true
+	}
+
+	introducing := ""
+	for file, fileRanges := range ranges {
+		for _, r := range fileRanges {
+			shas, err := blameCommits(file, r)
+			if err != nil {
+				Log(DEBUG, "Skipping follow-up detection: %v", err)
+				return FollowUp{}, false
+			}
+			for _, sha := range shas {
+				if !recent[sha] || (introducing != "" && sha != introducing) {
+					return FollowUp{}, false
+				}
+				introducing = sha
+			}
+		}
+	}
+	if introducing == "" {
+		return FollowUp{}, false
+	}
+
+	followUp := FollowUp{SHA: introducing, Subject: commitSubject(introducing)}
+	Log(INFO, "Staged changes modify lines introduced by recent commit %s", shortSHA(followUp.SHA))
+	return followUp, true
+}
+
+// followUpReference formats the line referencing the commit being followed up on
+func followUpReference(followUp FollowUp) string {
+	return strings.TrimSpace(fmt.Sprintf("Follow-up to %s %s", shortSHA(followUp.SHA), followUp.Subject))
+}
+
+// shortSHA abbreviates a commit hash for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported forges, where pull requests are created
+const (
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+)
+
+// forgeCLI describes the command line tool used to create pull requests on a forge
+type forgeCLI struct {
+	Command     string // Executable name
+	Name        string // Name shown to the user, e.g. "GitHub CLI (gh)"
+	InstallURL  string
+	RequestName string // What the forge calls a pull request
+}
+
+// forgeCLIFor returns the CLI for the configured forge (GitHub when unset)
+func forgeCLIFor(forge string) forgeCLI {
+	if forge == ForgeGitLab {
+		return forgeCLI{Command: "glab", Name: "GitLab CLI (glab)", InstallURL: "https://gitlab.com/gitlab-org/cli", RequestName: "MR"}
+	}
+	return forgeCLI{Command: "gh", Name: "GitHub CLI (gh)", InstallURL: "https://cli.github.com/", RequestName: "PR"}
+}
+
+// forgeDisplayName returns the forge's name for messages
+func forgeDisplayName(forge string) string {
+	if forge == ForgeGitLab {
+		return "GitLab"
+	}
+	return "GitHub"
+}
+
+// createRequestArgs returns the CLI arguments that create a pull request, or a GitLab merge
+// request, with the given title and body. With opts.UseFill the CLI derives both from the commits.
+func createRequestArgs(opts PROptions, title string, body string, bodyFile string) []string {
+	if opts.Forge == ForgeGitLab {
+		// --yes skips glab's confirmation prompt
+		args := []string{"mr", "create", "--target-branch", opts.TargetBranch, "--yes"}
+		if opts.Draft {
+			args = append(args, "--draft")
+		}
+		args = append(args, requestMetadataArgs(opts)...)
+		if opts.UseFill {
+			return append(args, "--fill")
+		}
+		return append(args, "--title", title, "--description", body)
+	}
+	args := []string{"pr", "create", "--base", opts.TargetBranch}
+	if opts.Draft {
+		args = append(args, "--draft")
+	}
+	args = append(args, requestMetadataArgs(opts)...)
+	if opts.UseFill {
+		return append(args, "--fill", "--body-file", bodyFile)
+	}
+	return append(args, "--title", title, "--body-file", bodyFile)
+}
+
+// requestMetadataArgs returns the arguments that add reviewers, assignees and labels to a
+// new PR. gh and glab both take comma-separated lists.
+func requestMetadataArgs(opts PROptions) []string {
+	var args []string
+	if len(opts.Reviewers) > 0 {
+		args = append(args, "--reviewer", strings.Join(opts.Reviewers, ","))
+	}
+	if len(opts.Assignees) > 0 {
+		args = append(args, "--assignee", strings.Join(opts.Assignees, ","))
+	}
+	if len(opts.Labels) > 0 {
+		args = append(args, "--label", strings.Join(opts.Labels, ","))
+	}
+	return args
+}
+
+// extractRequestURL finds the URL of the created pull request in the CLI output. gh prints
+// it on a line of its own; glab prints it indented below the MR title.
+func extractRequestURL(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, "https://") || strings.HasPrefix(field, "http://") {
+				return field, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("couldn't extract URL from output")
+}
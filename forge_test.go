@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubForgeCLI installs a fake gh or glab that prints output and saves its arguments, one per
+// line, to the returned file
+func stubForgeCLI(t *testing.T, name string, output string) string {
+	t.Helper()
+	argsFile := filepath.Join(t.TempDir(), "args")
+	outputFile := filepath.Join(t.TempDir(), "output")
+	writeFile(t, filepath.Dir(outputFile), "output", output)
+	stubCommand(t, name, `printf '%s\n' "$@" > "`+argsFile+`"; cat "`+outputFile+`"`)
+	return argsFile
+}
+
+// readArgs returns the arguments saved by a stub from stubForgeCLI
+func readArgs(t *testing.T, argsFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("the stub wasn't run: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// writeMessageFile writes a PR message to a temporary file and returns its path
+func writeMessageFile(t *testing.T, message string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "message", message)
+	return filepath.Join(dir, "message")
+}
+
+func TestCreatePullRequestGitLab(t *testing.T) {
+	newTestRepoWithRemote(t)
+	argsFile := stubForgeCLI(t, "glab", "\nCreating merge request for feature into main in group/project\n\n"+
+		"!12 Add retries (feature)\n https://gitlab.com/group/project/-/merge_requests/12\n\n")
+
+	url, err := createPullRequest(writeMessageFile(t, "Add retries\n\nRetry failed requests."), PROptions{TargetBranch: "main", Forge: ForgeGitLab})
+	if err != nil {
+		t.Fatalf("createPullRequest() error: %v", err)
+	}
+	if want := "https://gitlab.com/group/project/-/merge_requests/12"; url != want {
+		t.Errorf("createPullRequest() = %q, want %q", url, want)
+	}
+	want := []string{"mr", "create", "--target-branch", "main", "--yes", "--title", "Add retries", "--description", "Retry failed requests."}
+	if got := readArgs(t, argsFile); strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("glab arguments = %q, want %q", got, want)
+	}
+}
+
+func TestExtractRequestURL(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"https://github.com/o/r/pull/3\n", "https://github.com/o/r/pull/3"},
+		{"Warning: 1 uncommitted change\nhttps://github.com/o/r/pull/4\n", "https://github.com/o/r/pull/4"},
+		{"!7 Fix (b)\n https://gitlab.example.com/g/p/-/merge_requests/7\n", "https://gitlab.example.com/g/p/-/merge_requests/7"},
+		{"no url here", ""},
+	}
+	for _, tt := range tests {
+		got, err := extractRequestURL(tt.output)
+		if got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("extractRequestURL(%q) = %q, %v, want %q", tt.output, got, err, tt.want)
+		}
+	}
+}
+
+func TestCreatePullRequestDraft(t *testing.T) {
+	newTestRepoWithRemote(t)
+	argsFile := stubForgeCLI(t, "gh", "https://github.com/o/r/pull/5\n")
+
+	if _, err := createPullRequest(writeMessageFile(t, "Add retries\n\nBody"), PROptions{TargetBranch: "main", Draft: true}); err != nil {
+		t.Fatalf("createPullRequest() error: %v", err)
+	}
+	args := readArgs(t, argsFile)
+	if strings.Join(args[:5], " ") != "pr create --base main --draft" {
+		t.Errorf("gh arguments = %q, want --draft forwarded", args)
+	}
+
+	if _, err := createPullRequest(writeMessageFile(t, "Add retries\n\nBody"), PROptions{TargetBranch: "main"}); err != nil {
+		t.Fatalf("createPullRequest() error: %v", err)
+	}
+	for _, arg := range readArgs(t, argsFile) {
+		if arg == "--draft" {
+			t.Errorf("gh arguments include --draft without -draft")
+		}
+	}
+}
+
+func TestCreateRequestArgs(t *testing.T) {
+	metadata := PROptions{TargetBranch: "main", Reviewers: []string{"alice", "bob"}, Assignees: []string{"@me"}, Labels: []string{"bug"}}
+	tests := []struct {
+		name  string
+		opts  PROptions
+		title string
+		want  string
+	}{
+		{"gh", PROptions{TargetBranch: "main"}, "Title",
+			"pr create --base main --title Title --body-file body.md"},
+		{"gh with metadata", metadata, "Title",
+			"pr create --base main --reviewer alice,bob --assignee @me --label bug --title Title --body-file body.md"},
+		{"gh with fill", PROptions{TargetBranch: "dev", UseFill: true, Labels: []string{"a", "b"}}, "",
+			"pr create --base dev --label a,b --fill --body-file body.md"},
+		{"glab with metadata", PROptions{TargetBranch: "main", Forge: ForgeGitLab, Draft: true, Reviewers: []string{"alice"}}, "Title",
+			"mr create --target-branch main --yes --draft --reviewer alice --title Title --description Body"},
+		{"glab with fill", PROptions{TargetBranch: "main", Forge: ForgeGitLab, UseFill: true}, "",
+			"mr create --target-branch main --yes --fill"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(createRequestArgs(tt.opts, tt.title, "Body", "body.md"), " ")
+		if got != tt.want {
+			t.Errorf("%s: createRequestArgs() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequestMetadataArgsEmpty(t *testing.T) {
+	if args := requestMetadataArgs(PROptions{TargetBranch: "main"}); len(args) != 0 {
+		t.Errorf("requestMetadataArgs() = %q, want no arguments", args)
+	}
+}
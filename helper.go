@@ -1,21 +1,121 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
 	"path/filepath"
 	"encoding/json"
+	"regexp"
+	"runtime"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config structure to hold file paths and settings
 type Config struct {
+	// Template file paths, "notes:<ref>" specs, or the template content itself when it spans several lines
 	CommitTemplate string    `json:"commit_template"`
 	PRTemplate     string    `json:"pr_template"`
+	// Template content given directly in the config, used instead of commit_template/pr_template
+	CommitTemplateInline string `json:"commit_template_inline"`
+	PRTemplateInline     string `json:"pr_template_inline"`
 	LLM            LLMConfig `json:"llm"`
 	FirstLineLimit int       `json:"first_line_limit"` // Maximum length for the first line
+	// Prefix the commit subject with the detected change type, e.g. "[fix] ".
+	// This is a lightweight alternative to Conventional Commits ("fix: ").
+	SubjectPrefixFromType bool `json:"subject_prefix_from_type"`
+	AskIntent             bool `json:"ask_intent"` // Ask for the goal of the change before generating
+	// Commit message style: auto (detect from history), conventional, gitmoji or freeform
+	CommitStyle string `json:"commit_style"`
+	// Instruction used when the staged changes revert an earlier commit.
+	// {{subject}} is replaced with the reverted commit's subject and {{sha}} with its SHA.
+	RevertPrompt string `json:"revert_prompt"`
+	// Ask for confirmation before committing more than this many files (-1 disables the check)
+	ConfirmFileThreshold int `json:"confirm_file_threshold"`
+	// Tense for commit messages: imperative (default), past or present
+	Tense string `json:"tense"`
+	// Limits on the generated commit message (0 disables each limit). A message over a limit
+	// is sent back to the LLM once to be made more concise, then truncated if still too long.
+	MaxBodyLines    int `json:"max_body_lines"`
+	MaxMessageChars int `json:"max_message_chars"`
+	// Maximum length of body lines checked by -lint-message (0 disables the check)
+	BodyLineLimit int `json:"body_line_limit"`
+	// Words that must not appear in commit messages, checked by -lint-message
+	BannedWords []string `json:"banned_words"`
+	// Directory for temporary message files (supports ~ and environment variables).
+	// Defaults to $TMPDIR or the system temp directory.
+	TempDir string `json:"temp_dir"`
+	// Format for PR titles, e.g. "[{{ticket}}] {{title}}". Supports {{title}}, {{ticket}} and {{branch}}.
+	PRTitleFormat string `json:"pr_title_format"`
+	// Regexp and replacement that turn the branch name into the PR title with -title-from-branch,
+	// e.g. "^[^/]+/[A-Z]+-[0-9]+-(.*)$" and "$1". When unset, a built-in cleanup is used.
+	BranchTitlePattern     string `json:"branch_title_pattern"`
+	BranchTitleReplacement string `json:"branch_title_replacement"`
+	// Line endings of the message file opened in the editor: auto (CRLF on Windows), lf or crlf.
+	// The edited message is always converted back to LF before it is used.
+	LineEndings string `json:"line_endings"`
+	// Maximum number of LLM requests in flight at once across all parallel features (default 3)
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+	// Target branch for PRs when -target isn't given (default: the remote's default branch, then
+	// main or master, whichever exists (master if neither does))
+	DefaultTargetBranch string `json:"default_target_branch"`
+	// Shell command the generated message is piped through (stdin to stdout) before editing
+	PostProcessCommand string `json:"post_process_command"`
+	// Ask whether to commit, abort or regenerate when the editor is closed without changes
+	ConfirmUnchanged bool `json:"confirm_unchanged"`
+	// Require the commit message to mention every changed file (re-prompts once if some are missing)
+	RequireFileMentions bool `json:"require_file_mentions"`
+	// Lines starting with these prefixes are removed from commit messages, along with
+	// <!-- --> comments (default ["#"]; an empty list keeps all lines)
+	CommentPrefixes []string `json:"comment_prefixes"`
+	// Editor command for the message, taking precedence over $GIT_EDITOR, $VISUAL and $EDITOR.
+	// May include arguments, e.g. "code --wait".
+	Editor string `json:"editor"`
+	// Append a "Changelog: <entry>" trailer describing the user-facing impact, for changelog tools
+	ChangelogTrailer bool `json:"changelog_trailer"`
+	// Suffix appended when the first line is shortened to first_line_limit (default "…", "none" for no suffix)
+	FirstLineEllipsis string `json:"first_line_ellipsis"`
+	// Prices in USD per million tokens ({"input": ..., "output": ...}) by model, added to or
+	// replacing the built-in prices used for cost estimates. Dated variants match by prefix.
+	ModelPrices map[string]ModelPrice `json:"model_prices"`
+	// Approximate token budget for the diff (or commit list for PRs) sent to the LLM; larger
+	// input is truncated per file with a marker (0 disables the limit)
+	MaxDiffTokens int `json:"max_diff_tokens"`
+	// Paths (directories, files or globs) whose changes aren't sent to the LLM, e.g. lockfiles.
+	// A pattern without a slash matches the file name in any directory. The files are still committed.
+	ExcludePaths []string `json:"exclude_paths"`
+	// Paths (directories, files or globs) that commits may only touch with -allow-protected
+	ProtectedPaths []string `json:"protected_paths"`
+	// File of example diff/message pairs included in the commit prompt as few-shot examples
+	FewShotFile string `json:"few_shot_file"`
+	// Limits on the few-shot examples included: how many (default 3) and approximate tokens (default 2000)
+	FewShotMaxExamples int `json:"few_shot_max_examples"`
+	FewShotMaxTokens   int `json:"few_shot_max_tokens"`
+	// Where PRs are created: github (default, using gh) or gitlab (merge requests, using glab)
+	Forge string `json:"forge"`
+	// Give the LLM the message of the commit being amended, so it refines rather than replaces
+	// it (default true)
+	AmendPreserveIntent *bool `json:"amend_preserve_intent"`
+	// Reviewers, assignees and labels added to new PRs, unless replaced by the matching flag
+	PRReviewers []string `json:"pr_reviewers"`
+	PRAssignees []string `json:"pr_assignees"`
+	PRLabels    []string `json:"pr_labels"`
+	// Labels the LLM may choose from for the PR with -suggest-labels
+	AllowedLabels []string `json:"allowed_labels"`
+	// What PR descriptions are generated from: the commits' subjects (default) or their full messages
+	PRCommitMessages string `json:"pr_commit_messages"`
+	// Trailers added to generated commit messages before the length limits are enforced, e.g.
+	// for -close-issue. Set at runtime, not in the config file.
+	ExtraTrailers []string `json:"-"`
 }
 
 // expandPath expands the tilde in file paths to the user's home directory
@@ -34,55 +134,254 @@ func expandPath(path string) string {
 	return path
 }
 
+// tempDirOverride is the configured temp_dir, already expanded
+var tempDirOverride string
+
+// tempDir returns the directory temporary files are created in: the configured
+// temp_dir if set, otherwise os.TempDir (which honors $TMPDIR)
+func tempDir() string {
+	if tempDirOverride != "" {
+		return tempDirOverride
+	}
+	return os.TempDir()
+}
+
+// setTempDir configures the directory for temporary files, creating it if needed
+func setTempDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	dir = expandPath(os.ExpandEnv(dir))
+	Log(DEBUG, "Using temp directory: %s", dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		Log(ERROR, "Failed to create temp directory: %v", err)
+		return fmt.Errorf("failed to create temp directory %s: %v", dir, err)
+	}
+	tempDirOverride = dir
+	return nil
+}
+
 // loadConfig reads the configuration file.
 func loadConfig(configPath string) (Config, error) {
 	Log(INFO, "Loading config from: %s", configPath)
-	var config Config
-	data, err := ioutil.ReadFile(configPath)
+	data, err := readConfigData(configPath)
 	if err != nil {
 		Log(ERROR, "Failed to read config file: %v", err)
-		return config, fmt.Errorf("failed to read config file: %v", err)
+		return Config{}, err
+	}
+	return parseConfig(data)
+}
+
+// isYAMLConfig reports whether a config file is YAML, based on its extension
+func isYAMLConfig(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// readConfigData reads a config file as JSON. YAML files are converted to the equivalent
+// JSON, so both formats share the same keys, strict key checking and defaults.
+func readConfigData(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if !isYAMLConfig(path) {
+		return data, nil
+	}
+
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config file: %v", err)
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML config file: %v", err)
+	}
+	return converted, nil
+}
+
+// decodeConfig strictly decodes config JSON, rejecting unknown keys with a suggestion
+func decodeConfig(data []byte, config *Config) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		hint := configKeyHint(err)
+		Log(ERROR, "Failed to parse config file: %v%s", err, hint)
+		return fmt.Errorf("failed to parse config file: %v%s", err, hint)
 	}
-	if err := json.Unmarshal(data, &config); err != nil {
-		Log(ERROR, "Failed to parse config file: %v", err)
-		return config, fmt.Errorf("failed to parse config file: %v", err)
+	return nil
+}
+
+// parseConfig decodes config JSON and applies the defaults
+func parseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := decodeConfig(data, &config); err != nil {
+		return config, err
 	}
 	
 	// Expand paths
 	Log(DEBUG, "Expanding template paths")
-	config.CommitTemplate = expandPath(config.CommitTemplate)
-	config.PRTemplate = expandPath(config.PRTemplate)
+	if !isInlineTemplate(config.CommitTemplate) {
+		config.CommitTemplate = expandPath(config.CommitTemplate)
+	}
+	if !isInlineTemplate(config.PRTemplate) {
+		config.PRTemplate = expandPath(config.PRTemplate)
+	}
+	config.FewShotFile = expandPath(config.FewShotFile)
+	
+	// Inline templates replace the template specs, which readTemplate then returns as-is
+	if config.CommitTemplateInline != "" {
+		if config.CommitTemplate != "" {
+			return config, fmt.Errorf("both commit_template and commit_template_inline are set in config; use only one")
+		}
+		config.CommitTemplate = ensureMultiline(config.CommitTemplateInline)
+	}
+	if config.PRTemplateInline != "" {
+		if config.PRTemplate != "" {
+			return config, fmt.Errorf("both pr_template and pr_template_inline are set in config; use only one")
+		}
+		config.PRTemplate = ensureMultiline(config.PRTemplateInline)
+	}
 	
 	// Set default LLM values if not provided
+	if _, err := providerFor(config.LLM); err != nil {
+		Log(ERROR, "Invalid LLM provider in config: %s", config.LLM.Provider)
+		return config, err
+	}
 	if config.LLM.Model == "" {
-		Log(DEBUG, "Setting default LLM model: gpt-4")
-		config.LLM.Model = "gpt-4"
+		config.LLM.Model = defaultModel(config.LLM.Provider)
+		Log(DEBUG, "Setting default LLM model: %s", config.LLM.Model)
 	}
 	if config.LLM.Temperature == 0 {
 		Log(DEBUG, "Setting default LLM temperature: 0.7")
 		config.LLM.Temperature = 0.7
 	}
+	if config.LLM.TemperatureStep == 0 {
+		Log(DEBUG, "Setting default LLM temperature step: 0.1")
+		config.LLM.TemperatureStep = 0.1
+	}
 	if config.LLM.MaxTokens == 0 {
 		Log(DEBUG, "Setting default LLM max tokens: 1000")
 		config.LLM.MaxTokens = 1000
 	}
+	if config.LLM.MaxQuestions == 0 {
+		config.LLM.MaxQuestions = defaultMaxQuestions
+	} else if config.LLM.MaxQuestions < 0 {
+		return config, fmt.Errorf("invalid max_questions %d in config (must be at least 1)", config.LLM.MaxQuestions)
+	}
 	
 	// Try to get API key from environment if not in config
-	if config.LLM.APIKey == "" {
+	if config.LLM.APIKey == "" && apiKeyEnvVar(config.LLM.Provider) != "" {
 		Log(DEBUG, "API key not found in config, checking environment")
-		config.LLM.APIKey = os.Getenv("OPENAI_KEY")
+		envVar := apiKeyEnvVar(config.LLM.Provider)
+		config.LLM.APIKey = os.Getenv(envVar)
 		if config.LLM.APIKey == "" {
-			Log(WARN, "OPENAI_KEY not found in environment")
+			Log(WARN, "%s not found in environment", envVar)
 		} else {
-			Log(DEBUG, "OPENAI_KEY found in environment with length: %d", len(config.LLM.APIKey))
+			Log(DEBUG, "%s found in environment: %s", envVar, maskSecret(config.LLM.APIKey))
 		}
 	}
+	registerSecret(config.LLM.APIKey)
+	if config.LLM.EnableQuestions {
+		Log(WARN, "enable_questions is deprecated, use enable_commit_questions and enable_pr_questions")
+		config.LLM.EnableCommitQuestions = true
+		config.LLM.EnablePRQuestions = true
+	}
 	
 	// Set default first line limit if not provided
 	if config.FirstLineLimit == 0 {
 		Log(DEBUG, "Setting default first line limit: 72")
 		config.FirstLineLimit = 72 // Common Git standard
 	}
+	if config.FirstLineEllipsis == "" {
+		config.FirstLineEllipsis = defaultFirstLineEllipsis
+	}
+	if config.AmendPreserveIntent == nil {
+		preserve := true
+		config.AmendPreserveIntent = &preserve
+	}
+	if config.FewShotMaxExamples == 0 {
+		config.FewShotMaxExamples = defaultFewShotMaxExamples
+	}
+	if config.FewShotMaxTokens == 0 {
+		config.FewShotMaxTokens = defaultFewShotMaxTokens
+	}
+	
+	// Set default tense if not provided
+	switch config.Tense {
+	case "":
+		Log(DEBUG, "Setting default tense: imperative")
+		config.Tense = TenseImperative
+	case TenseImperative, TensePast, TensePresent:
+	default:
+		Log(ERROR, "Invalid tense in config: %s", config.Tense)
+		return config, fmt.Errorf("invalid tense %q in config (expected imperative, past or present)", config.Tense)
+	}
+	
+	for _, list := range []struct {
+		Key    string
+		Values []string
+	}{{"pr_reviewers", config.PRReviewers}, {"pr_assignees", config.PRAssignees}, {"pr_labels", config.PRLabels}, {"allowed_labels", config.AllowedLabels}} {
+		for _, value := range list.Values {
+			if strings.TrimSpace(value) == "" {
+				return config, fmt.Errorf("empty value in %s in config", list.Key)
+			}
+		}
+	}
+	
+	switch config.PRCommitMessages {
+	case "":
+		config.PRCommitMessages = PRCommitMessagesSubject
+	case PRCommitMessagesSubject, PRCommitMessagesFull:
+	default:
+		Log(ERROR, "Invalid pr_commit_messages in config: %s", config.PRCommitMessages)
+		return config, fmt.Errorf("invalid pr_commit_messages %q in config (expected subject or full)", config.PRCommitMessages)
+	}
+	
+	switch config.Forge {
+	case "", ForgeGitHub, ForgeGitLab:
+	default:
+		Log(ERROR, "Invalid forge in config: %s", config.Forge)
+		return config, fmt.Errorf("invalid forge %q in config (expected github or gitlab)", config.Forge)
+	}
+	
+	// Set default line endings if not provided
+	switch config.LineEndings {
+	case "":
+		config.LineEndings = LineEndingsAuto
+	case LineEndingsAuto, LineEndingsLF, LineEndingsCRLF:
+	default:
+		Log(ERROR, "Invalid line endings in config: %s", config.LineEndings)
+		return config, fmt.Errorf("invalid line_endings %q in config (expected auto, lf or crlf)", config.LineEndings)
+	}
+	
+	if config.BranchTitlePattern != "" {
+		if _, err := regexp.Compile(config.BranchTitlePattern); err != nil {
+			return config, fmt.Errorf("invalid branch_title_pattern %q in config: %v", config.BranchTitlePattern, err)
+		}
+	}
+	
+	// Set default confirmation threshold for large changesets if not provided
+	if config.ConfirmFileThreshold == 0 {
+		Log(DEBUG, "Setting default confirm file threshold: 50")
+		config.ConfirmFileThreshold = 50
+	}
+	
+	// Set default comment prefixes if not provided (an explicit empty list disables them)
+	if config.CommentPrefixes == nil {
+		config.CommentPrefixes = defaultCommentPrefixes
+	}
+	
+	// Set default concurrency limit if not provided
+	if config.MaxConcurrentRequests == 0 {
+		Log(DEBUG, "Setting default max concurrent requests: %d", defaultMaxConcurrentRequests)
+		config.MaxConcurrentRequests = defaultMaxConcurrentRequests
+	} else if config.MaxConcurrentRequests < 0 {
+		return config, fmt.Errorf("invalid max_concurrent_requests %d in config (must be at least 1)", config.MaxConcurrentRequests)
+	}
 	
 	Log(INFO, "Config loaded successfully")
 	return config, nil
@@ -91,38 +390,314 @@ func loadConfig(configPath string) (Config, error) {
 // getStagedDiff retrieves the diff of staged changes.
 func getStagedDiff() (string, error) {
 	Log(INFO, "Getting staged diff from git")
-	cmd := exec.Command("git", "diff", "--cached")
+	cmd := gitCommand("", stagedDiffArgs()...)
 	output, err := cmd.Output()
 	if err != nil {
 		Log(ERROR, "Failed to get staged diff: %v", err)
-		return "", fmt.Errorf("failed to get staged diff: %v", err)
+		return "", &GitError{Err: fmt.Errorf("failed to get staged diff: %v", err)}
 	}
 	diffSize := len(output)
 	Log(DEBUG, "Retrieved staged diff (%d bytes)", diffSize)
 	return string(output), nil
 }
 
-// createCommitMessage generates a commit message using the template file and LLM.
-func createCommitMessage(diff string, templatePath string, llmConfig LLMConfig, firstLineLimit int) (string, error) {
-	Log(INFO, "Creating commit message using template: %s", templatePath)
+// notesTemplatePrefix marks a template spec that is read from a git notes ref instead of a file
+const notesTemplatePrefix = "notes:"
+
+// isInlineTemplate reports whether a template spec is the template content itself.
+// Paths never contain newlines, so any multi-line spec is treated as inline content.
+func isInlineTemplate(spec string) bool {
+	return strings.Contains(spec, "\n")
+}
+
+// ensureMultiline terminates inline template content with a newline so it is recognized as inline
+func ensureMultiline(content string) string {
+	if strings.HasSuffix(content, "\n") {
+		return content
+	}
+	return content + "\n"
+}
+
+// templateLabel describes a template spec for log messages
+func templateLabel(spec string) string {
+	if isInlineTemplate(spec) {
+		return "inline template from config"
+	}
+	return spec
+}
+
+// readTemplate reads a template from a file path, or from a git notes ref
+// when the spec has the form "notes:<ref>" (read with `git notes --ref=<ref> show`).
+// Inline template content is returned as-is.
+func readTemplate(spec string) ([]byte, error) {
+	if isInlineTemplate(spec) {
+		Log(DEBUG, "Using inline template from config")
+		return []byte(spec), nil
+	}
+	if strings.HasPrefix(spec, notesTemplatePrefix) {
+		ref := strings.TrimPrefix(spec, notesTemplatePrefix)
+		if ref == "" {
+			return nil, fmt.Errorf("empty notes ref in template spec %q", spec)
+		}
+		Log(DEBUG, "Reading template from git notes ref: %s", ref)
+		cmd := gitCommand("", "notes", "--ref="+ref, "show")
+		output, err := cmd.Output()
+		if err != nil {
+			Log(ERROR, "Failed to read template from notes ref %s: %v", ref, err)
+			return nil, fmt.Errorf("failed to read template from notes ref %s: %v", ref, err)
+		}
+		return output, nil
+	}
+	return ioutil.ReadFile(spec)
+}
+
+// getStagedFiles returns the paths of the staged files
+func getStagedFiles() ([]string, error) {
+	Log(DEBUG, "Getting staged file list from git")
+	cmd := gitCommand("", stagedDiffArgs("--name-only")...)
+	output, err := cmd.Output()
+	if err != nil {
+		Log(ERROR, "Failed to get staged files: %v", err)
+		return nil, &GitError{Err: fmt.Errorf("failed to get staged files: %v", err)}
+	}
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// confirm asks a yes/no question on stdin and reports whether the user answered yes
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Actions offered after the message has been opened in the editor
+const (
+	messageCommit     = "c"
+	messageAbort      = "a"
+	messageRegenerate = "r"
+	messageEdit       = "e"
+)
+
+// messageFileUnchanged reports whether the edited message file still holds the generated message
+func messageFileUnchanged(path string, generated string) bool {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(cutAtScissors(string(content))) == strings.TrimSpace(generated)
+}
+
+// askUnchangedAction asks what to do with a message the user didn't edit
+func askUnchangedAction(canRegenerate bool) string {
+	prompt := "Message unchanged. [c]ommit / [a]bort / [r]egenerate? "
+	if !canRegenerate {
+		prompt = "Message unchanged. [c]ommit / [a]bort? "
+	}
+	return askMessageAction(prompt, canRegenerate, false)
+}
+
+// askEditedAction asks what to do with the message once the editor is closed
+func askEditedAction(canRegenerate bool) string {
+	prompt := "[c]ommit / [r]egenerate / [e]dit / [a]bort? "
+	if !canRegenerate {
+		prompt = "[c]ommit / [e]dit / [a]bort? "
+	}
+	return askMessageAction(prompt, canRegenerate, true)
+}
+
+// askMessageAction repeats prompt until one of the offered actions is chosen.
+// A closed stdin counts as abort.
+func askMessageAction(prompt string, canRegenerate bool, canEdit bool) string {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print(prompt)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return messageAbort
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "c", "commit":
+			return messageCommit
+		case "a", "abort":
+			return messageAbort
+		case "r", "regenerate":
+			if canRegenerate {
+				return messageRegenerate
+			}
+		case "e", "edit":
+			if canEdit {
+				return messageEdit
+			}
+		}
+	}
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal
+func stdinIsTerminal() bool {
+	return isTerminal(os.Stdin)
+}
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal rather than a pipe or file
+func stdoutIsTerminal() bool {
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// The null device is a character device too
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
+
+// confirmLargeChangeset asks for confirmation when more files are staged than the threshold allows
+func confirmLargeChangeset(files []string, threshold int) bool {
+	if threshold < 0 || len(files) <= threshold {
+		return true
+	}
+	Log(WARN, "%d files staged, above the confirmation threshold of %d", len(files), threshold)
+	fmt.Printf("You are about to commit %d files, including:\n", len(files))
+	shown := files
+	if len(shown) > 10 {
+		shown = shown[:10]
+	}
+	for _, file := range shown {
+		fmt.Printf("  %s\n", file)
+	}
+	if len(files) > len(shown) {
+		fmt.Printf("  ... and %d more\n", len(files)-len(shown))
+	}
+	return confirm("Continue?")
+}
+
+// createCommitMessage generates a commit message for the staged diff using the template file and LLM.
+func createCommitMessage(diff string, config Config) (string, error) {
+	return createCommitMessageForDiff(diff, config, true)
+}
+
+// createCommitMessageForDiff generates a commit message for diff, which is the staged diff if staged
+// is set. For other diffs, such as those of existing commits being reworded, follow-up detection and
+// the {{diffstat}} template variable are skipped, as they look at HEAD and the index.
+func createCommitMessageForDiff(diff string, config Config, staged bool) (string, error) {
+	templatePath := config.CommitTemplate
+	llmConfig := config.LLM
+	firstLineLimit := config.FirstLineLimit
+
+	Log(INFO, "Creating commit message using template: %s", templateLabel(templatePath))
 	if diff == "" {
 		Log(ERROR, "No changes staged for commit")
-		return "", fmt.Errorf("no changes staged. Please stage changes before committing.")
+		return "", fmt.Errorf("%w. Please stage changes before committing.", ErrNoStagedChanges)
 	}
 
-	Log(DEBUG, "Reading commit template file")
-	template, err := ioutil.ReadFile(templatePath)
+	rawDiff := diff
+	diff, err := filterDiff(diff, commitDiffFilters(config))
+	if err != nil {
+		return "", err
+	}
+
+	Log(DEBUG, "Reading commit template")
+	template, err := readTemplate(templatePath)
 	if err != nil {
 		Log(ERROR, "Failed to read commit template: %v", err)
 		return "", fmt.Errorf("failed to read commit template: %v", err)
 	}
 
-	// Generate commit message using LLM
-	Log(INFO, "Generating commit message using LLM model: %s", llmConfig.Model)
-	message, err := GenerateCommitMessage(diff, llmConfig, string(template))
-	if err != nil {
-		Log(ERROR, "LLM generation failed: %v", err)
-		return "", fmt.Errorf("LLM generation failed: %v", err)
+	if config.FewShotFile != "" {
+		examples, err := loadFewShotExamples(config.FewShotFile, config.FewShotMaxExamples, config.FewShotMaxTokens)
+		if err != nil {
+			Log(ERROR, "Failed to load few-shot examples: %v", err)
+			return "", err
+		}
+		llmConfig.FewShotExamples = examples
+	}
+
+	revert, isRevert := detectRevert(rawDiff)
+	style := resolveCommitStyle(config.CommitStyle)
+	if isRevert {
+		Log(INFO, "Staged changes revert commit %s", revert.SHA)
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, revertInstruction(revert, config.RevertPrompt))
+	} else if instruction := commitStyleInstruction(style); instruction != "" {
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, instruction)
+	}
+	if !isRevert {
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, tenseInstruction(config.Tense))
+	}
+
+	followUp, isFollowUp := FollowUp{}, false
+	if !isRevert && staged {
+		followUp, isFollowUp = detectFollowUp(diff)
+	}
+	if isFollowUp {
+		llmConfig.ExtraContext = append(llmConfig.ExtraContext, fmt.Sprintf(
+			"These changes modify lines introduced by the recent commit %s (%q); describe them as a follow-up to it", shortSHA(followUp.SHA), followUp.Subject))
+	}
+
+	if usesTemplateVariables(string(template)) {
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, templateVariablesInstruction)
+	}
+	if config.RequireFileMentions && !isRevert {
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, fileMentionsInstruction(diffFiles(rawDiff)))
+	}
+
+	if language := primaryLanguage(diff); language == "" {
+		Log(DEBUG, "No single language dominates the changes")
+	} else if !isRevert {
+		Log(DEBUG, "Detected primary language of the changes: %s", language)
+		llmConfig.ExtraContext = append(llmConfig.ExtraContext, fmt.Sprintf("These are primarily changes to %s code", language))
+	}
+
+	files := diffFiles(diff)
+	if !isRevert && style != StyleGitmoji {
+		if scope := suggestedScope(files, style); scope != "" {
+			Log(DEBUG, "Computed scope %q from common directory %q", scope, commonDir(files))
+			llmConfig.ExtraContext = append(llmConfig.ExtraContext, fmt.Sprintf(
+				"All changed files are under %s. Suggested scope for the first line: %s", commonDir(files), scope))
+		} else {
+			Log(DEBUG, "No common directory for the changed files, not suggesting a scope")
+		}
+	}
+
+	cacheKey := generationCacheKey("commit", llmConfig.Model, withExtraInstructions(string(template), llmConfig.ExtraInstructions), withExtraContext(diff, llmConfig.ExtraContext)+formatFewShotExamples(llmConfig.FewShotExamples))
+	message, cached := "", false
+	if llmConfig.EnableCache {
+		message, cached = loadCachedGeneration(cacheKey)
+	}
+
+	if !cached {
+		// Generate commit message using LLM
+		Log(INFO, "Generating commit message using LLM model: %s", llmConfig.Model)
+		message, err = GenerateCommitMessage(diff, llmConfig, string(template))
+		if err != nil {
+			Log(ERROR, "LLM generation failed: %v", err)
+			return "", fmt.Errorf("LLM generation failed: %w", err)
+		}
+		if llmConfig.EnableCache {
+			saveCachedGeneration(cacheKey, CacheEntry{Kind: "commit", DiffHash: hashString(diff), Model: llmConfig.Model, CreatedAt: time.Now(), Message: message})
+		}
+	}
+	message = separateSubject(message)
+	
+	if isRevert {
+		message = enforceRevertFormat(message, revert)
+	} else if config.SubjectPrefixFromType && style == StyleConventional {
+		Log(DEBUG, "Skipping subject type prefix: conventional commit style already includes the type")
+	} else if config.SubjectPrefixFromType {
+		changeType := classifyChange(rawDiff)
+		Log(DEBUG, "Detected change type: %s", changeType)
+		message = prefixSubject(message, fmt.Sprintf("[%s] ", changeType))
 	}
 	
 	// Apply first line length limit if specified
@@ -130,112 +705,599 @@ func createCommitMessage(diff string, templatePath string, llmConfig LLMConfig,
 		message = trimFirstLine(message, firstLineLimit)
 	}
 	
+	if config.RequireFileMentions && !isRevert {
+		message = enforceFileMentions(message, config, diffFiles(rawDiff))
+	}
+	
+	if isFollowUp {
+		message = appendTrailer(message, followUpReference(followUp))
+	}
+	
+	if usesTemplateVariables(string(template)) {
+		if !usesTemplateVariables(message) {
+			Log(WARN, "The generated message dropped the template variables, so the diffstat and file list weren't filled in")
+		}
+		message = expandTemplateVariables(message, diffFiles(rawDiff), staged)
+	}
+	
+	// Trailers go in before the limits are enforced, which keep them intact
+	if config.ChangelogTrailer && !isRevert {
+		message = appendChangelogTrailer(message, config.LLM)
+	}
+	for _, trailer := range config.ExtraTrailers {
+		message = appendTrailer(message, trailer)
+	}
+	
+	message = enforceMessageLimits(message, config)
+	
+	if !isRevert {
+		checkTense(message, config.Tense)
+	}
+	
 	Log(DEBUG, "Commit message generated successfully (%d chars)", len(message))
 	return message, nil
 }
 
-// openInVim allows the user to edit the commit message.
-func openInVim(filename string) error {
-	Log(INFO, "Opening message in vim: %s", filename)
-	cmd := exec.Command("vim", filename)
+// emptyCommitInstruction tells the LLM that the commit is an intentional empty marker commit
+const emptyCommitInstruction = "This is an empty marker commit with no code changes, e.g. to trigger a deploy or a CI run. " +
+	"Write a short message stating the purpose of the marker; do not describe or invent code changes."
+
+// createEmptyCommitMessage generates a message for an empty commit from the branch and recent history
+func createEmptyCommitMessage(config Config) (string, error) {
+	llmConfig := config.LLM
+	Log(INFO, "Creating message for an empty commit using template: %s", templateLabel(config.CommitTemplate))
+
+	template, err := readTemplate(config.CommitTemplate)
+	if err != nil {
+		Log(ERROR, "Failed to read commit template: %v", err)
+		return "", fmt.Errorf("failed to read commit template: %v", err)
+	}
+
+	llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, emptyCommitInstruction)
+	if instruction := commitStyleInstruction(resolveCommitStyle(config.CommitStyle)); instruction != "" {
+		llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, instruction)
+	}
+	llmConfig.ExtraInstructions = append(llmConfig.ExtraInstructions, tenseInstruction(config.Tense))
+
+	var context strings.Builder
+	context.WriteString("No changes are staged; this is an empty commit.\n")
+	if branch, err := getCurrentBranch(""); err == nil {
+		context.WriteString(fmt.Sprintf("Current branch: %s\n", branch))
+	}
+	if subjects, err := getRecentSubjects(5); err == nil && len(subjects) > 0 {
+		context.WriteString("Recent commits:\n")
+		for _, subject := range subjects {
+			context.WriteString("- " + subject + "\n")
+		}
+	}
+
+	message, err := GenerateCommitMessage(context.String(), llmConfig, string(template))
+	if err != nil {
+		Log(ERROR, "LLM generation failed: %v", err)
+		return "", fmt.Errorf("LLM generation failed: %w", err)
+	}
+	message = separateSubject(message)
+	if config.FirstLineLimit > 0 {
+		message = trimFirstLine(message, config.FirstLineLimit)
+	}
+	for _, trailer := range config.ExtraTrailers {
+		message = appendTrailer(message, trailer)
+	}
+	return enforceMessageLimits(message, config), nil
+}
+
+// editorOverride is the configured editor, which takes precedence over the environment
+var editorOverride string
+
+// resolveEditor returns the editor command: the configured editor, then $GIT_EDITOR,
+// $VISUAL and $EDITOR, falling back to vim
+func resolveEditor() string {
+	if editorOverride != "" {
+		return editorOverride
+	}
+	for _, env := range []string{"GIT_EDITOR", "VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(env)); editor != "" {
+			Log(DEBUG, "Using editor from $%s", env)
+			return editor
+		}
+	}
+	return "vim"
+}
+
+// editorCommand builds the command that opens filename in the editor. Like git, the editor
+// is run through the shell so it can include arguments, e.g. "code --wait" for GUI editors
+// that would otherwise return before the file is closed.
+func editorCommand(editor string, filename string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		args := append(strings.Fields(editor), filename)
+		return exec.Command(args[0], args[1:]...)
+	}
+	return exec.Command("sh", "-c", editor+` "$@"`, editor, filename)
+}
+
+// openInEditor allows the user to edit the commit message.
+func openInEditor(filename string) error {
+	editor := resolveEditor()
+	Log(INFO, "Opening message in %s: %s", editor, filename)
+	cmd := editorCommand(editor, filename)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
 	if err != nil {
-		Log(ERROR, "Error while editing with vim: %v", err)
-	} else {
-		Log(DEBUG, "Vim editor closed successfully")
+		Log(ERROR, "Error while editing with %s: %v", editor, err)
+		if editorNotStarted(err) {
+			return fmt.Errorf("%w: %s: %v", ErrEditorNotFound, editor, err)
+		}
+		return err
+	}
+	Log(DEBUG, "Editor closed successfully")
+	return nil
+}
+
+// editorNotStarted reports whether err means the editor couldn't be run at all, rather than
+// exiting with an error. Through the shell, that's exit status 126 (not executable) or 127
+// (not found).
+func editorNotStarted(err error) bool {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		return true
 	}
-	return err
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		return code == 126 || code == 127
+	}
+	return false
+}
+
+// CommitOptions controls how the commit is made
+type CommitOptions struct {
+	NoVerify   bool   // Skip the pre-commit and commit-msg hooks
+	SigningKey string // Sign with this GPG/SSH key instead of user.signingkey
+	AllowEmpty bool   // Allow a commit that records no changes
+	Amend      bool   // Replace the previous commit instead of creating a new one
+	Scissors   bool   // Let git cut the message at its scissors line (--cleanup=scissors)
+}
+
+// signingKeyPattern loosely matches GPG key IDs/fingerprints, emails and SSH key paths
+var signingKeyPattern = regexp.MustCompile(`^(0x)?[0-9A-Fa-f]{8,40}$|^[^\s@]+@[^\s@]+$|^[~./].+$|^key::.+$`)
+
+// validateSigningKey loosely checks the format of a signing key; git reports keys that don't exist
+func validateSigningKey(key string) error {
+	if !signingKeyPattern.MatchString(key) {
+		return fmt.Errorf("signing key %q doesn't look like a GPG key ID, fingerprint, email or SSH key path", key)
+	}
+	return nil
 }
 
 // commitChanges commits using the edited message.
-func commitChanges(messageFile string) error {
+func commitChanges(messageFile string, opts CommitOptions) error {
 	Log(INFO, "Committing changes with message file: %s", messageFile)
-	cmd := exec.Command("git", "commit", "-F", messageFile)
+	args := []string{"commit", "-F", messageFile}
+	if opts.NoVerify {
+		Log(DEBUG, "Skipping commit hooks (--no-verify)")
+		args = append(args, "--no-verify")
+	}
+	if opts.SigningKey != "" {
+		Log(DEBUG, "Signing commit with key: %s", opts.SigningKey)
+		args = append(args, "-S"+opts.SigningKey)
+	}
+	if opts.Amend {
+		Log(DEBUG, "Amending the previous commit (--amend)")
+		args = append(args, "--amend")
+	}
+	if opts.AllowEmpty {
+		Log(DEBUG, "Allowing an empty commit (--allow-empty)")
+		args = append(args, "--allow-empty")
+	}
+	if opts.Scissors {
+		// git only cuts at the scissors line when the message is edited, so ask for an
+		// edit with an editor that leaves the already edited file as it is
+		Log(DEBUG, "Cutting the message at the scissors line (--cleanup=scissors)")
+		args = append(args, "--cleanup=scissors", "--edit")
+	}
+	cmd := gitCommand("", args...)
+	cmd.Env = os.Environ()
+	if opts.Scissors {
+		cmd.Env = append(cmd.Env, "GIT_EDITOR=true")
+	}
+	// git doesn't report which hook failed a commit, but its trace shows the hooks it ran
+	traceFile := ""
+	if !opts.NoVerify {
+		if file, err := ioutil.TempFile(tempDir(), "gitscribe_trace"); err == nil {
+			file.Close()
+			traceFile = file.Name()
+			defer os.Remove(traceFile)
+			cmd.Env = append(cmd.Env, "GIT_TRACE="+traceFile)
+		}
+	}
+	var stderr bytes.Buffer
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 	err := cmd.Run()
 	if err != nil {
 		Log(ERROR, "Failed to commit changes: %v", err)
-	} else {
-		Log(INFO, "Changes committed successfully")
+		if opts.SigningKey != "" && strings.Contains(stderr.String(), "failed to sign") {
+			return &GitError{Err: fmt.Errorf("git could not sign the commit with key %s (see the output above): %v", opts.SigningKey, err)}
+		}
+		if hook := failedCommitHook(traceFile); hook != "" {
+			return &GitError{Err: commitHookError(hook, messageFile, err)}
+		}
+		return &GitError{Err: err}
+	}
+	Log(INFO, "Changes committed successfully")
+	return nil
+}
+
+// commitHookPattern matches the trace line of git running a commit hook
+var commitHookPattern = regexp.MustCompile(`run_command: .*[/\\](pre-commit|prepare-commit-msg|commit-msg)( |$)`)
+
+// failedCommitHook returns the name of the commit hook that failed the commit: the last hook
+// git's trace shows it ran, as git stops at a failing hook. It returns an empty string when no
+// hook ran, so a commit that failed for another reason isn't blamed on a hook.
+func failedCommitHook(traceFile string) string {
+	if traceFile == "" {
+		return ""
+	}
+	trace, err := ioutil.ReadFile(traceFile)
+	if err != nil {
+		Log(DEBUG, "Could not read the git trace: %v", err)
+		return ""
+	}
+	hook := ""
+	for _, line := range strings.Split(string(trace), "\n") {
+		if match := commitHookPattern.FindStringSubmatch(line); match != nil {
+			hook = match[1]
+		}
+	}
+	if hook != "" {
+		Log(DEBUG, "git ran the %s hook last", hook)
+	}
+	return hook
+}
+
+// commitHookError saves the message to a recoverable location in the git directory
+// and returns an error explaining how to retry after a commit hook failed
+func commitHookError(hook string, messageFile string, commitErr error) error {
+	message, err := ioutil.ReadFile(messageFile)
+	if err != nil {
+		return fmt.Errorf("%s hook failed (%v) and the message could not be saved: %v", hook, commitErr, err)
+	}
+
+	savedPath := filepath.Join(tempDir(), "GITSCRIBE_EDITMSG")
+	if output, err := gitCommand("", "rev-parse", "--git-path", "GITSCRIBE_EDITMSG").Output(); err == nil {
+		savedPath = strings.TrimSpace(string(output))
+		if repoDir != "" && !filepath.IsAbs(savedPath) {
+			savedPath = filepath.Join(repoDir, savedPath)
+		}
+	}
+	if err := ioutil.WriteFile(savedPath, message, 0644); err != nil {
+		return fmt.Errorf("%s hook failed (%v) and the message could not be saved: %v", hook, commitErr, err)
+	}
+	Log(INFO, "Saved commit message to %s", savedPath)
+
+	return fmt.Errorf("%s hook failed (see its output above); fix the issues and re-run.\n"+
+		"Your message was saved to %s (commit it with: git commit -F %s)\n"+
+		"To skip the hooks, re-run with -no-verify", hook, savedPath, savedPath)
+}
+
+// repoDir is the repository git commands run in when no directory is given (set by -repo-dir).
+// When empty, git commands run in the current directory.
+var repoDir string
+
+// gitCommand builds a git command that runs in dir, or in repoDir when dir is empty
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	if dir == "" {
+		dir = repoDir
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// validateRepoDir checks that dir exists and is inside a git repository
+func validateRepoDir(dir string) error {
+	Log(DEBUG, "Validating repository directory: %s", dir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("repository directory %s: %v", dir, err)
 	}
-	return err
+	if !info.IsDir() {
+		return fmt.Errorf("repository directory %s is not a directory", dir)
+	}
+	if err := gitCommand(dir, "rev-parse", "--git-dir").Run(); err != nil {
+		return &GitError{Err: fmt.Errorf("%s is %w", dir, ErrNotGitRepo)}
+	}
+	return nil
 }
 
-// getCommitMessages retrieves all commit messages between the current branch and the target branch
-func getCommitMessages(targetBranch string) (string, error) {
+// fallbackTargetBranches are the conventional default branch names, tried in order when the
+// default branch can't be detected. If none of them exists, git's own default, master, is used.
+var fallbackTargetBranches = []string{"main", "master"}
+
+// resolveTargetBranch picks the PR target branch for the repository in dir.
+// Precedence: the -target flag, then default_target_branch from the config,
+// then the default branch of origin, then main or master, whichever exists (master if neither does).
+func resolveTargetBranch(dir string, flagValue string, configured string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if configured != "" {
+		Log(DEBUG, "Using default_target_branch from config: %s", configured)
+		return configured
+	}
+	if detected := detectDefaultBranch(dir); detected != "" {
+		Log(DEBUG, "Using the remote's default branch as target: %s", detected)
+		return detected
+	}
+	for _, branch := range fallbackTargetBranches {
+		if branchExists(dir, branch) {
+			Log(DEBUG, "Could not detect the default branch, using %s", branch)
+			return branch
+		}
+	}
+	Log(DEBUG, "Could not detect the default branch, using master")
+	return "master"
+}
+
+// detectDefaultBranch returns the branch origin/HEAD points to or, if that isn't set (e.g. for
+// a remote added after cloning), the default branch GitHub reports. It returns "" if neither
+// is available.
+func detectDefaultBranch(dir string) string {
+	output, err := gitCommand(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/")
+	}
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+	cmd := exec.Command("gh", "repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name")
+	cmd.Dir = dir
+	if dir == "" {
+		cmd.Dir = repoDir
+	}
+	output, err = cmd.Output()
+	if err != nil {
+		Log(DEBUG, "Could not get the default branch from gh: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// branchExists reports whether the repository in dir has the branch locally or as a
+// remote-tracking branch of origin
+func branchExists(dir string, branch string) bool {
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		if gitCommand(dir, "rev-parse", "--verify", "--quiet", ref).Run() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compareRef returns the ref the current branch is compared against for the target branch:
+// origin/<target> if that remote-tracking branch exists, since the local target branch is often
+// behind it, and the local target branch otherwise
+func compareRef(dir string, targetBranch string) string {
+	if gitCommand(dir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+targetBranch).Run() == nil {
+		return "origin/" + targetBranch
+	}
+	return targetBranch
+}
+
+// mergeBase returns the commit where branch forked from ref
+func mergeBase(dir string, ref string, branch string) (string, error) {
+	output, err := gitCommand(dir, "merge-base", ref, branch).Output()
+	if err != nil {
+		Log(ERROR, "Failed to find the merge base of %s and %s: %v", ref, branch, err)
+		return "", &GitError{Err: fmt.Errorf("failed to find where %s forked from %s: %v", branch, ref, err)}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// fetchTargetBranch updates origin's copy of the target branch, so the branch is compared
+// against its current state
+func fetchTargetBranch(dir string, targetBranch string) error {
+	Log(INFO, "Fetching %s from origin", targetBranch)
+	cmd := gitCommand(dir, "fetch", "origin", targetBranch)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		Log(ERROR, "Failed to fetch %s: %v", targetBranch, err)
+		return &GitError{Err: fmt.Errorf("failed to fetch %s from origin: %v", targetBranch, err)}
+	}
+	return nil
+}
+
+// getCurrentBranch returns the name of the checked out branch in dir. It returns an error
+// in detached HEAD state, where there is no branch to push or open a PR from.
+func getCurrentBranch(dir string) (string, error) {
+	cmd := gitCommand(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		Log(ERROR, "Failed to get current branch: %v", err)
+		return "", &GitError{Err: fmt.Errorf("failed to get current branch: %v", err)}
+	}
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		Log(ERROR, "Repository is in detached HEAD state")
+		return "", fmt.Errorf("cannot create a PR from detached HEAD; check out a branch first")
+	}
+	Log(DEBUG, "Current branch: %s", branch)
+	return branch, nil
+}
+
+// Values of pr_commit_messages
+const (
+	PRCommitMessagesSubject = "subject"
+	PRCommitMessagesFull    = "full"
+)
+
+// commitMarker starts each commit in the output of git log when full messages are collected
+const commitMarker = "--- gitscribe commit ---"
+
+// getCommitMessages retrieves the messages of the current branch's commits since it forked from the
+// target branch (see compareRef), oldest first, for the repository in dir (the current directory
+// when empty). Only the subjects are retrieved unless fullMessages is set.
+func getCommitMessages(dir string, targetBranch string, fullMessages bool) ([]string, error) {
 	Log(INFO, "Getting commit messages unique to the current branch")
 	// Get current branch name
-	cmdBranch := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	currentBranch, err := cmdBranch.Output()
+	currentBranchStr, err := getCurrentBranch(dir)
 	if err != nil {
-		Log(ERROR, "Failed to get current branch: %v", err)
-		return "", fmt.Errorf("failed to get current branch: %v", err)
+		return nil, err
 	}
-	currentBranchStr := strings.TrimSpace(string(currentBranch))
-	Log(DEBUG, "Current branch: %s", currentBranchStr)
 	
 	// Get only commits that are in the current branch but not in the target branch
 	// This shows commits unique to the feature branch
-	Log(DEBUG, "Fetching unique commits in %s not in %s", currentBranchStr, targetBranch)
+	ref := compareRef(dir, targetBranch)
+	Log(DEBUG, "Fetching unique commits in %s not in %s", currentBranchStr, ref)
 	
-	// Use git cherry to find commits unique to the current branch
-	// This is more reliable for finding unique commits than complex log commands
-	cmd := exec.Command("git", "cherry", "-v", targetBranch, currentBranchStr)
+	// List the commits since the merge base rather than comparing with the target directly,
+	// so commits merged into the target after the branch forked aren't involved
+	base, err := mergeBase(dir, ref, currentBranchStr)
+	if err != nil {
+		return nil, err
+	}
+	format := "--format=%s"
+	if fullMessages {
+		format = "--format=" + commitMarker + "%n%B"
+	}
+	cmd := gitCommand(dir, "log", "--reverse", format, base+".."+currentBranchStr)
 	output, err := cmd.Output()
 	if err != nil {
 		Log(ERROR, "Failed to get unique commits: %v", err)
-		return "", fmt.Errorf("failed to get unique commits: %v", err)
+		return nil, &GitError{Err: fmt.Errorf("failed to get unique commits: %v", err)}
 	}
 	
-	// Process the output to extract just the commit messages
-	lines := strings.Split(string(output), "\n")
 	var commitMessages []string
-	
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		// git cherry output format is "+ <sha> <message>"
-		// We want to extract just the message part
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) >= 3 {
-			commitMessages = append(commitMessages, parts[2])
+	if fullMessages {
+		commitMessages = parseFullCommitLog(string(output))
+	} else {
+		// Each line of the output is a commit subject
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			commitMessages = append(commitMessages, line)
 		}
 	}
 	
-	result := strings.Join(commitMessages, "\n")
 	commitCount := len(commitMessages)
 	
 	Log(INFO, "Retrieved %d unique commit messages", commitCount)
-	return result, nil
+	return commitMessages, nil
+}
+
+// parseFullCommitLog splits git log output in which each message follows a commitMarker line
+// into the commit messages
+func parseFullCommitLog(output string) []string {
+	var messages []string
+	var current []string
+	inCommit := false
+	flush := func() {
+		if message := strings.TrimSpace(strings.Join(current, "\n")); inCommit && message != "" {
+			messages = append(messages, message)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(normalizeLineEndings(output), "\n") {
+		if line == commitMarker {
+			flush()
+			inCommit = true
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return messages
+}
+
+// joinCommitMessages joins commit messages into the text the PR description is generated from:
+// one per line if they are all subjects, otherwise separated by "---" lines
+func joinCommitMessages(messages []string) string {
+	for _, message := range messages {
+		if strings.Contains(message, "\n") {
+			return strings.Join(messages, "\n\n---\n\n")
+		}
+	}
+	return strings.Join(messages, "\n")
+}
+
+// getDiffStat returns the diffstat of the current branch against the target branch
+func getDiffStat(dir string, targetBranch string) (string, error) {
+	ref := compareRef(dir, targetBranch)
+	Log(INFO, "Getting diffstat against %s", ref)
+	cmd := gitCommand(dir, "diff", "--stat", ref+"...HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		Log(ERROR, "Failed to get diffstat: %v", err)
+		return "", &GitError{Err: fmt.Errorf("failed to get diffstat: %v", err)}
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// appendDiffStat appends a diffstat to a PR message under a "Changed files" heading
+func appendDiffStat(message string, diffStat string) string {
+	if strings.TrimSpace(diffStat) == "" {
+		return message
+	}
+	return fmt.Sprintf("%s\n\n## Changed files\n\n```\n%s\n```", strings.TrimRight(message, "\n"), diffStat)
 }
 
 // createPRMessage generates a PR message using the template file, commit messages, and LLM
-func createPRMessage(commits string, templatePath string, llmConfig LLMConfig, firstLineLimit int) (string, error) {
-	Log(INFO, "Creating PR message using template: %s", templatePath)
+func createPRMessage(commits string, templatePath string, llmConfig LLMConfig, firstLineLimit int, maxTokens int) (string, error) {
+	Log(INFO, "Creating PR message using template: %s", templateLabel(templatePath))
 	if commits == "" {
 		Log(ERROR, "No commits found between branches")
 		return "", fmt.Errorf("no commits found between branches. Please make some commits first.")
 	}
+	commits = truncateLines(commits, maxTokens)
+	return createPRStyleMessage("pr", commits, GeneratePRMessage, templatePath, llmConfig, firstLineLimit)
+}
 
-	Log(DEBUG, "Reading PR template file")
-	template, err := ioutil.ReadFile(templatePath)
+// createPRStyleCommitMessage generates a commit message from the staged diff using the PR
+// template, for workflows where a single commit makes up the whole pull request
+func createPRStyleCommitMessage(diff string, config Config) (string, error) {
+	Log(INFO, "Creating PR-style commit message")
+	if diff == "" {
+		Log(ERROR, "No changes staged for commit")
+		return "", fmt.Errorf("%w. Please stage changes before committing.", ErrNoStagedChanges)
+	}
+	diff, err := filterDiff(diff, commitDiffFilters(config))
+	if err != nil {
+		return "", err
+	}
+	return createPRStyleMessage("pr-commit", diff, GenerateSquashMessage, config.PRTemplate, config.LLM, config.FirstLineLimit)
+}
+
+// createPRStyleMessage generates a message following the PR template from input (commit
+// messages or a diff, depending on generate), using the generation cache when enabled
+func createPRStyleMessage(kind string, input string, generate func(string, LLMConfig, string) (string, error), templatePath string, llmConfig LLMConfig, firstLineLimit int) (string, error) {
+	Log(DEBUG, "Reading PR template")
+	template, err := readTemplate(templatePath)
 	if err != nil {
 		Log(ERROR, "Failed to read PR template: %v", err)
 		return "", fmt.Errorf("failed to read PR template: %v", err)
 	}
 
-	// Generate PR message using LLM
-	Log(INFO, "Generating PR message using LLM model: %s", llmConfig.Model)
-	message, err := GeneratePRMessage(commits, llmConfig, string(template))
-	if err != nil {
-		Log(ERROR, "LLM generation failed: %v", err)
-		return "", fmt.Errorf("LLM generation failed: %v", err)
+	cacheKey := generationCacheKey(kind, llmConfig.Model, withExtraInstructions(string(template), llmConfig.ExtraInstructions), withExtraContext(input, llmConfig.ExtraContext))
+	message, cached := "", false
+	if llmConfig.EnableCache {
+		message, cached = loadCachedGeneration(cacheKey)
+	}
+
+	if !cached {
+		// Generate PR message using LLM
+		Log(INFO, "Generating PR message using LLM model: %s", llmConfig.Model)
+		message, err = generate(input, llmConfig, string(template))
+		if err != nil {
+			Log(ERROR, "LLM generation failed: %v", err)
+			return "", fmt.Errorf("LLM generation failed: %w", err)
+		}
+		if llmConfig.EnableCache {
+			saveCachedGeneration(cacheKey, CacheEntry{Kind: kind, DiffHash: hashString(input), Model: llmConfig.Model, CreatedAt: time.Now(), Message: message})
+		}
 	}
 	
 	// Apply first line length limit if specified
@@ -247,96 +1309,175 @@ func createPRMessage(commits string, templatePath string, llmConfig LLMConfig, f
 	return message, nil
 }
 
-// createPullRequest creates a PR on GitHub using the gh CLI
-func createPullRequest(prMessageFile string, targetBranch string) (string, error) {
+// markdownHeadingPattern matches the "#" markers of a markdown heading
+var markdownHeadingPattern = regexp.MustCompile(`^#{1,6}\s+`)
+
+// splitTitleAndBody splits a generated message into its first line (the title)
+// and the remaining lines (the body). A title written as a markdown heading, as models
+// sometimes do for PR descriptions, loses its "#" markers.
+func splitTitleAndBody(message string) (string, string) {
+	message = strings.TrimSpace(normalizeLineEndings(message))
+	parts := strings.SplitN(message, "\n", 2)
+	title := strings.TrimSpace(markdownHeadingPattern.ReplaceAllString(parts[0], ""))
+	body := ""
+	if len(parts) > 1 {
+		body = strings.TrimSpace(parts[1])
+	}
+	return title, body
+}
+
+// ticketPattern matches ticket IDs such as TEAM-123 in branch names
+var ticketPattern = regexp.MustCompile(`[A-Z][A-Z0-9]+-[0-9]+`)
+
+// detectTicket returns the first ticket ID found in a branch name, if any
+func detectTicket(branch string) string {
+	return ticketPattern.FindString(strings.ToUpper(branch))
+}
+
+// formatPRTitle applies the configured title format. If the format references {{ticket}}
+// but no ticket was detected, it warns and returns the title unchanged.
+func formatPRTitle(format string, title string, branch string) string {
+	if format == "" {
+		return title
+	}
+	ticket := detectTicket(branch)
+	if strings.Contains(format, "{{ticket}}") && ticket == "" {
+		Log(WARN, "PR title format references {{ticket}} but no ticket was found in branch %s", branch)
+		fmt.Printf("Warning: no ticket found in branch name %q; using the title without the format.\n", branch)
+		return title
+	}
+	replacer := strings.NewReplacer("{{title}}", title, "{{ticket}}", ticket, "{{branch}}", branch)
+	formatted := strings.TrimSpace(replacer.Replace(format))
+	Log(DEBUG, "Formatted PR title: %s", formatted)
+	return formatted
+}
+
+// emptyPRMessage returns a placeholder PR message for a branch with no commits that differ from the target
+func emptyPRMessage(targetBranch string) (string, error) {
+	branch, err := getCurrentBranch("")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Empty PR from %s\n\nThis PR has no changes relative to %s. It was opened intentionally, for example to trigger CI.", branch, targetBranch), nil
+}
+
+// PROptions controls how a pull request is created
+type PROptions struct {
+	TargetBranch string
+	UseFill      bool   // Let the CLI derive the title and body from commits (--fill)
+	TitleFormat  string // See formatPRTitle
+	// Derive the title from the branch name instead of the message's first line (see branchTitle)
+	TitleFromBranch        bool
+	BranchTitlePattern     string
+	BranchTitleReplacement string
+	TitleLimit             int    // Maximum title length, as for the first line of messages
+	Forge                  string // github (default) or gitlab
+	Draft                  bool   // Create the PR as a draft
+	Reviewers              []string
+	Assignees              []string
+	Labels                 []string
+}
+
+// createPullRequest creates a PR on GitHub using the gh CLI, or a merge request on GitLab
+// using glab when opts.Forge is gitlab. It returns the URL of the PR.
+//
+// By default the edited message is split into a title (first line) and a body
+// (everything after it), which are passed to the CLI explicitly. When opts.UseFill is
+// true, the CLI is instead run with --fill, which lets it derive the title and body
+// from the branch's commits; depending on the gh version this may take precedence
+// over the generated body.
+func createPullRequest(prMessageFile string, opts PROptions) (string, error) {
+	targetBranch := opts.TargetBranch
+	cli := forgeCLIFor(opts.Forge)
 	Log(INFO, "Creating pull request to target branch: %s", targetBranch)
-	// Check if gh CLI is installed
-	if _, err := exec.LookPath("gh"); err != nil {
-		Log(ERROR, "GitHub CLI (gh) not found")
-		return "", fmt.Errorf("GitHub CLI (gh) not found. Please install it from https://cli.github.com/")
+	// Check if the forge CLI is installed
+	if _, err := exec.LookPath(cli.Command); err != nil {
+		Log(ERROR, "%s not found", cli.Name)
+		return "", fmt.Errorf("%s not found. Please install it from %s", cli.Name, cli.InstallURL)
 	}
 	
 	// Get current branch name
-	cmdBranch := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	currentBranch, err := cmdBranch.Output()
+	currentBranchStr, err := getCurrentBranch("")
 	if err != nil {
-		Log(ERROR, "Failed to get current branch: %v", err)
-		return "", fmt.Errorf("failed to get current branch: %v", err)
+		return "", err
 	}
-	currentBranchStr := strings.TrimSpace(string(currentBranch))
-	Log(DEBUG, "Current branch: %s", currentBranchStr)
 	
 	// Push the current branch to remote
 	Log(INFO, "Pushing commits to remote...")
-	pushCmd := exec.Command("git", "push", "-u", "origin", currentBranchStr)
-	pushCmd.Stdout = os.Stdout
-	pushCmd.Stderr = os.Stderr
-	if err := pushCmd.Run(); err != nil {
-		Log(ERROR, "Failed to push to remote: %v", err)
-		return "", fmt.Errorf("failed to push to remote: %v", err)
+	if err := pushBranch(currentBranchStr); err != nil {
+		return "", err
 	}
 	
-	// Create PR using gh CLI
-	Log(INFO, "Creating PR on GitHub...")
-	cmd := exec.Command("gh", "pr", "create", "--base", targetBranch, "--fill", "--body-file", prMessageFile)
+	Log(INFO, "Creating %s on %s...", cli.RequestName, forgeDisplayName(opts.Forge))
+	var args []string
+	if opts.UseFill {
+		Log(DEBUG, "Letting %s derive the %s title and body from commits (--fill)", cli.Command, cli.RequestName)
+		args = createRequestArgs(opts, "", "", prMessageFile)
+	} else {
+		data, err := ioutil.ReadFile(prMessageFile)
+		if err != nil {
+			Log(ERROR, "Failed to read PR message file: %v", err)
+			return "", fmt.Errorf("failed to read PR message file: %v", err)
+		}
+		title, body := splitTitleAndBody(string(data))
+		if opts.TitleFromBranch {
+			title, err = branchTitle(currentBranchStr, opts.BranchTitlePattern, opts.BranchTitleReplacement)
+			if err != nil {
+				return "", err
+			}
+			title = trimFirstLine(title, opts.TitleLimit)
+		}
+		if title == "" {
+			Log(ERROR, "PR message is empty")
+			return "", fmt.Errorf("PR message is empty; cannot derive a PR title")
+		}
+		title = formatPRTitle(opts.TitleFormat, title, currentBranchStr)
+		Log(DEBUG, "Using generated PR title: %s", title)
+	
+		// Write the body on its own so the title line isn't repeated in the description
+		bodyFile := prMessageFile + ".body"
+		if err := ioutil.WriteFile(bodyFile, []byte(body), 0644); err != nil {
+			Log(ERROR, "Failed to write PR body file: %v", err)
+			return "", fmt.Errorf("failed to write PR body file: %v", err)
+		}
+		defer os.Remove(bodyFile)
+		args = createRequestArgs(opts, title, body, bodyFile)
+	}
+	cmd := exec.Command(cli.Command, args...)
+	cmd.Dir = repoDir
 	
 	// Capture the output to get the PR URL
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		Log(ERROR, "Failed to create PR: %v\n%s", err, string(output))
-		return "", fmt.Errorf("failed to create PR: %v\n%s", err, string(output))
-	}
-	
-	// Extract PR URL from output
-	outputStr := string(output)
-	
-	// Find the URL in the output (usually the last line)
-	lines := strings.Split(strings.TrimSpace(outputStr), "\n")
-	var prURL string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "https://") {
-			prURL = strings.TrimSpace(line)
-			break
-		}
+		Log(ERROR, "Failed to create %s: %v\n%s", cli.RequestName, err, string(output))
+		return "", fmt.Errorf("failed to create %s: %v\n%s", cli.RequestName, err, string(output))
 	}
 	
-	if prURL == "" {
-		Log(WARN, "PR created but couldn't extract URL from output")
-		return "", fmt.Errorf("PR created but couldn't extract URL from output")
+	prURL, err := extractRequestURL(string(output))
+	if err != nil {
+		Log(WARN, "%s created but couldn't extract URL from output", cli.RequestName)
+		return "", fmt.Errorf("%s created but %v", cli.RequestName, err)
 	}
 	
-	Log(INFO, "PR created successfully: %s", prURL)
+	Log(INFO, "%s created successfully: %s", cli.RequestName, prURL)
 	return prURL, nil
 }
 
-// loadConfigFromPrioritizedLocations tries to load config from multiple locations in order of priority
-func loadConfigFromPrioritizedLocations(customPath string) (Config, error) {
-	Log(INFO, "Loading config from prioritized locations")
-	// If a custom path is provided, try that first
-	if customPath != "" {
-		Log(DEBUG, "Custom config path provided: %s", customPath)
-		expandedPath := expandPath(customPath)
-		config, err := loadConfig(expandedPath)
-		if err == nil {
-			Log(INFO, "Successfully loaded config from custom path")
-			return config, nil
-		}
-		// If custom path fails, don't fall back - return the error
-		Log(ERROR, "Failed to load config from specified path %s: %v", customPath, err)
-		return Config{}, fmt.Errorf("failed to load config from specified path %s: %v", customPath, err)
-	}
+// configFileNames are the config file names looked for in each location, JSON first
+var configFileNames = []string{".gitscribe_config.json", ".gitscribe_config.yaml", ".gitscribe_config.yml"}
 
-	// List of potential config locations in order of priority
-	configLocations := []string{
-		".gitscribe_config.json", // Current working directory
+// configSearchPaths returns the potential config locations in order of priority
+func configSearchPaths() []string {
+	configDirs := []string{
+		"", // Current working directory
 	}
 
 	// Add user's home directory location
 	home, err := os.UserHomeDir()
 	if err == nil {
-		homePath := filepath.Join(home, ".gitscribe", ".gitscribe_config.json")
-		Log(DEBUG, "Adding home directory config path: %s", homePath)
-		configLocations = append(configLocations, homePath)
+		homeDir := filepath.Join(home, ".gitscribe")
+		Log(DEBUG, "Adding home directory config location: %s", homeDir)
+		configDirs = append(configDirs, homeDir)
 	} else {
 		Log(WARN, "Could not get user home directory: %v", err)
 	}
@@ -345,33 +1486,30 @@ func loadConfigFromPrioritizedLocations(customPath string) (Config, error) {
 	execPath, err := os.Executable()
 	if err == nil {
 		execDir := filepath.Dir(execPath)
-		execConfigPath := filepath.Join(execDir, ".gitscribe_config.json")
-		Log(DEBUG, "Adding executable directory config path: %s", execConfigPath)
-		configLocations = append(configLocations, execConfigPath)
+		Log(DEBUG, "Adding executable directory config location: %s", execDir)
+		configDirs = append(configDirs, execDir)
 	} else {
 		Log(WARN, "Could not get executable path: %v", err)
 	}
 
-	// Try each location in order
-	Log(DEBUG, "Trying %d potential config locations", len(configLocations))
-	var lastErr error
-	for _, location := range configLocations {
-		Log(DEBUG, "Trying config location: %s", location)
-		config, err := loadConfig(location)
-		if err == nil {
-			Log(INFO, "Successfully loaded config from: %s", location)
-			return config, nil
+	var configLocations []string
+	for _, dir := range configDirs {
+		for _, name := range configFileNames {
+			configLocations = append(configLocations, filepath.Join(dir, name))
 		}
-		lastErr = err
-		Log(DEBUG, "Failed to load from %s: %v", location, err)
 	}
-
-	// If we get here, we couldn't find a config file
-	Log(ERROR, "Could not find config file in any standard location")
-	return Config{}, fmt.Errorf("could not find config file in any standard location: %v", lastErr)
+	return configLocations
 }
 
-// trimFirstLine ensures the first line of a message doesn't exceed the specified limit
+// defaultFirstLineEllipsis is appended to a shortened first line unless configured otherwise
+const defaultFirstLineEllipsis = "…"
+
+// firstLineEllipsis is the suffix trimFirstLine appends (set from first_line_ellipsis; "none" disables it)
+var firstLineEllipsis = defaultFirstLineEllipsis
+
+// trimFirstLine ensures the first line of a message doesn't exceed the specified limit.
+// It cuts at the last word boundary that leaves room for the ellipsis, falling back to
+// a hard cut when the line has no whitespace to break at.
 func trimFirstLine(message string, limit int) string {
 	if limit <= 0 {
 		return message // No limit specified
@@ -384,11 +1522,90 @@ func trimFirstLine(message string, limit int) string {
 		return message // Empty message
 	}
 	
-	// Check if first line exceeds the limit
-	if len(lines[0]) > limit {
-		Log(DEBUG, "First line exceeds limit (%d > %d), trimming", len(lines[0]), limit)
-		lines[0] = lines[0][:limit]
+	// Check if first line exceeds the limit, counting runes so the cut never splits a character
+	if runes := []rune(lines[0]); len(runes) > limit {
+		Log(DEBUG, "First line exceeds limit (%d > %d), trimming", len(runes), limit)
+		ellipsis := []rune(firstLineEllipsis)
+		if firstLineEllipsis == "none" || len(ellipsis) >= limit {
+			ellipsis = nil
+		}
+		cut := limit - len(ellipsis)
+		// Back off to the last whitespace, unless the cut already falls right before one
+		end := cut
+		for end > 0 && !unicode.IsSpace(runes[end]) {
+			end--
+		}
+		trimmed := strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace)
+		if trimmed == "" {
+			trimmed = string(runes[:cut])
+		}
+		lines[0] = trimmed + string(ellipsis)
 	}
 	
 	return strings.Join(lines, "\n")
-}
\ No newline at end of file
+}
+
+// trailerLinePattern matches a git trailer line such as "Closes: #12" or "Signed-off-by: A <a@b>"
+var trailerLinePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*: \S`)
+
+// appendTrailer appends a trailer line to a message, separated from the body by a blank line.
+// If the message already ends with a block of trailers, the line is added to that block so
+// that git recognizes all of them.
+func appendTrailer(message string, trailer string) string {
+	message = strings.TrimRight(message, "\n")
+	if strings.Contains(message, trailer) {
+		return message
+	}
+	if endsWithTrailers(message) && trailerLinePattern.MatchString(trailer) {
+		return message + "\n" + trailer
+	}
+	return message + "\n\n" + trailer
+}
+
+// endsWithTrailers reports whether the last paragraph of a message (after the subject) consists only of trailers
+func endsWithTrailers(message string) bool {
+	paragraphs := strings.Split(message, "\n\n")
+	if len(paragraphs) < 2 {
+		return false
+	}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if !trailerLinePattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTrailers splits a message into the text before its final trailer block and the block
+// itself, which is empty if the message doesn't end with trailers
+func splitTrailers(message string) (string, string) {
+	message = strings.TrimRight(message, "\n")
+	if !endsWithTrailers(message) {
+		return message, ""
+	}
+	idx := strings.LastIndex(message, "\n\n")
+	return message[:idx], message[idx+2:]
+}
+
+// separateSubject makes sure exactly one blank line separates the first line of a message
+// from its body, as git and tools like git log --oneline expect. Models sometimes leave the
+// blank line out or add several.
+func separateSubject(message string) string {
+	lines := strings.Split(strings.TrimSpace(normalizeLineEndings(message)), "\n")
+	body := lines[1:]
+	for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+		body = body[1:]
+	}
+	if len(body) == 0 {
+		return lines[0]
+	}
+	return lines[0] + "\n\n" + strings.Join(body, "\n")
+}
+
+// prefixSubject prepends prefix to the first line of a message unless it is already there
+func prefixSubject(message string, prefix string) string {
+	if strings.HasPrefix(message, prefix) {
+		return message
+	}
+	return prefix + message
+}
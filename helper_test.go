@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// newTestRepo creates a git repository with an initial commit on main in a temporary directory
+// and makes it the repository git commands run in
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "Test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	commitFile(t, dir, "README.md", "test\n", "Initial commit")
+
+	previous := repoDir
+	repoDir = dir
+	t.Cleanup(func() { repoDir = previous })
+	return dir
+}
+
+// runGit runs a git command in dir, failing the test if it fails, and returns its output
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// writeFile writes a file in dir, creating its directories
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes a file in dir and commits it with message
+func commitFile(t *testing.T, dir string, name string, content string, message string) {
+	t.Helper()
+	writeFile(t, dir, name, content)
+	runGit(t, dir, "add", "--", name)
+	runGit(t, dir, "commit", "-q", "-m", message)
+}
+
+// installHook writes an executable hook script into the repository in dir
+func installHook(t *testing.T, dir string, name string, script string) {
+	t.Helper()
+	writeFile(t, dir, filepath.Join(".git", "hooks", name), "#!/bin/sh\n"+script+"\n")
+	if err := os.Chmod(filepath.Join(dir, ".git", "hooks", name), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitChangesBlamesFailingHook(t *testing.T) {
+	dir := newTestRepo(t)
+	t.Setenv("TMPDIR", t.TempDir())
+	installHook(t, dir, "pre-commit", "exit 0")
+	installHook(t, dir, "commit-msg", "echo 'missing ticket' >&2; exit 1")
+	writeFile(t, dir, "a.txt", "a\n")
+	runGit(t, dir, "add", "a.txt")
+	messageFile := filepath.Join(t.TempDir(), "message")
+	writeFile(t, filepath.Dir(messageFile), "message", "Add a\n")
+
+	err := commitChanges(messageFile, CommitOptions{})
+	if err == nil || !strings.Contains(err.Error(), "commit-msg hook failed") {
+		t.Errorf("commitChanges() error = %v, want the commit-msg hook blamed", err)
+	}
+}
+
+func TestCommitChangesDoesNotBlameHookThatDidNotRun(t *testing.T) {
+	dir := newTestRepo(t)
+	t.Setenv("TMPDIR", t.TempDir())
+	installHook(t, dir, "commit-msg", "exit 0")
+	messageFile := filepath.Join(t.TempDir(), "message")
+	writeFile(t, filepath.Dir(messageFile), "message", "Nothing staged\n")
+
+	// With nothing staged, git fails before running the commit-msg hook
+	err := commitChanges(messageFile, CommitOptions{})
+	if err == nil {
+		t.Fatal("commitChanges() succeeded with nothing staged")
+	}
+	if strings.Contains(err.Error(), "hook failed") {
+		t.Errorf("commitChanges() error = %v, want no hook blamed", err)
+	}
+}
+
+// stubCommand puts an executable shell script named name first on PATH, so it runs instead of
+// the real command
+func stubCommand(t *testing.T, name string, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, name, "#!/bin/sh\n"+script+"\n")
+	if err := os.Chmod(filepath.Join(dir, name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveEditor(t *testing.T) {
+	tests := []struct {
+		configured string
+		env        map[string]string
+		want       string
+	}{
+		{"", map[string]string{}, "vim"},
+		{"", map[string]string{"EDITOR": "nano"}, "nano"},
+		{"", map[string]string{"EDITOR": "nano", "VISUAL": "code --wait"}, "code --wait"},
+		{"", map[string]string{"EDITOR": "nano", "VISUAL": "code --wait", "GIT_EDITOR": "emacs"}, "emacs"},
+		{"", map[string]string{"EDITOR": "nano", "GIT_EDITOR": "  "}, "nano"},
+		{"hx", map[string]string{"GIT_EDITOR": "emacs"}, "hx"},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"GIT_EDITOR", "VISUAL", "EDITOR"} {
+			t.Setenv(name, tt.env[name])
+		}
+		setEditor(t, tt.configured)
+		if got := resolveEditor(); got != tt.want {
+			t.Errorf("resolveEditor() with config %q and %v = %q, want %q", tt.configured, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestOpenInEditorRunsEditorWithArguments(t *testing.T) {
+	setEditor(t, "")
+	t.Setenv("GIT_EDITOR", "sed -i s/draft/final/")
+	dir := t.TempDir()
+	writeFile(t, dir, "message", "draft message\n")
+
+	if err := openInEditor(filepath.Join(dir, "message")); err != nil {
+		t.Fatalf("openInEditor() error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "message")); string(data) != "final message\n" {
+		t.Errorf("edited file = %q, want the editor's change", data)
+	}
+}
+
+func TestOpenInEditorErrors(t *testing.T) {
+	setEditor(t, "gitscribe-no-such-editor")
+	err := openInEditor(filepath.Join(t.TempDir(), "message"))
+	if exitCode(err) != exitConfigError {
+		t.Errorf("exitCode(%v) = %d, want %d for an editor that can't be started", err, exitCode(err), exitConfigError)
+	}
+
+	setEditor(t, "false")
+	err = openInEditor(filepath.Join(t.TempDir(), "message"))
+	if err == nil || exitCode(err) != exitFailure {
+		t.Errorf("openInEditor() error = %v, want exit code %d for an editor that fails", err, exitFailure)
+	}
+}
+
+func TestTrimFirstLineUTF8(t *testing.T) {
+	tests := []struct {
+		message string
+		limit   int
+		want    string
+	}{
+		// Counted in characters, not bytes, so these fit as they are
+		{"Réparer l'accès ✨", 17, "Réparer l'accès ✨"},
+		{"🚀🚀🚀", 3, "🚀🚀🚀"},
+		// Cut without splitting a multi-byte character
+		{"Corrige la requête émise à l'éditeur", 20, "Corrige la requête…"},
+		{"🚀🚀🚀🚀🚀🚀", 4, "🚀🚀🚀…"},
+		{"Ajouté ünïcödé\n\nCorps du message", 10, "Ajouté…\n\nCorps du message"},
+	}
+	for _, tt := range tests {
+		got := trimFirstLine(tt.message, tt.limit)
+		if got != tt.want {
+			t.Errorf("trimFirstLine(%q, %d) = %q, want %q", tt.message, tt.limit, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("trimFirstLine(%q, %d) = %q, which isn't valid UTF-8", tt.message, tt.limit, got)
+		}
+	}
+}
+
+func TestTrimFirstLineWordBoundary(t *testing.T) {
+	tests := []struct {
+		message  string
+		limit    int
+		ellipsis string
+		want     string
+	}{
+		{"Add retry logic to the client", 0, "…", "Add retry logic to the client"},
+		{"Add retry logic", 15, "…", "Add retry logic"},
+		{"Add retry logic to the client", 16, "…", "Add retry logic…"},
+		{"Add retry logic to the client", 10, "...", "Add..."},
+		{"Add retry logic to the client", 16, "none", "Add retry logic"},
+		// No whitespace to break at: a hard cut
+		{"Refactor_everything_at_once", 10, "…", "Refactor_…"},
+		// Limits too short for the ellipsis drop it
+		{"Add retry logic", 1, "…", "A"},
+		{"Add retry logic", 3, "...", "Add"},
+	}
+	for _, tt := range tests {
+		previous := firstLineEllipsis
+		firstLineEllipsis = tt.ellipsis
+		got := trimFirstLine(tt.message, tt.limit)
+		firstLineEllipsis = previous
+		if got != tt.want {
+			t.Errorf("trimFirstLine(%q, %d) with ellipsis %q = %q, want %q", tt.message, tt.limit, tt.ellipsis, got, tt.want)
+		}
+	}
+}
+
+func TestSplitTitleAndBody(t *testing.T) {
+	tests := []struct {
+		message string
+		title   string
+		body    string
+	}{
+		{"Add retries\n\nRetry failed requests.", "Add retries", "Retry failed requests."},
+		{"# Add retries\n\n## Summary\nRetry failed requests.", "Add retries", "## Summary\nRetry failed requests."},
+		{"### Add retries ", "Add retries", ""},
+		{"\r\n  Add retries\r\n\r\nBody\r\n", "Add retries", "Body"},
+		{"#123 fix the login", "#123 fix the login", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		title, body := splitTitleAndBody(tt.message)
+		if title != tt.title || body != tt.body {
+			t.Errorf("splitTitleAndBody(%q) = %q, %q, want %q, %q", tt.message, title, body, tt.title, tt.body)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// defaultTimeoutSeconds bounds an LLM API request when timeout_seconds isn't set
+const defaultTimeoutSeconds = 60
+
+// requestTimeout returns the configured timeout for an API request
+func requestTimeout(config LLMConfig) time.Duration {
+	if config.TimeoutSeconds > 0 {
+		return time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	return defaultTimeoutSeconds * time.Second
+}
+
+// doHTTPRequest sends an API request and reads the whole response body. The request is
+// cancelled when the configured timeout expires or when the user presses Ctrl-C.
+func doHTTPRequest(req *http.Request, config LLMConfig) (*http.Response, []byte, error) {
+	timeout := requestTimeout(config)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, &APIError{Err: requestError(ctx, timeout, fmt.Errorf("failed to send request: %v", err))}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, &APIError{Err: requestError(ctx, timeout, fmt.Errorf("failed to read response: %v", err))}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, nil, &APIError{Err: fmt.Errorf("%w (%s): %s", ErrRateLimited, resp.Status, strings.TrimSpace(string(body)))}
+	}
+	return resp, body, nil
+}
+
+// streamHTTPRequest sends an API request and passes a successful response body to read as it
+// arrives. An unsuccessful response is read whole and returned for the caller to inspect.
+func streamHTTPRequest(req *http.Request, config LLMConfig, read func(io.Reader) error) (*http.Response, []byte, error) {
+	timeout := requestTimeout(config)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, &APIError{Err: requestError(ctx, timeout, fmt.Errorf("failed to send request: %v", err))}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, nil, &APIError{Err: requestError(ctx, timeout, fmt.Errorf("failed to read response: %v", err))}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, nil, &APIError{Err: fmt.Errorf("%w (%s): %s", ErrRateLimited, resp.Status, strings.TrimSpace(string(body)))}
+		}
+		return resp, body, nil
+	}
+
+	if err := read(resp.Body); err != nil {
+		return nil, nil, &APIError{Err: requestError(ctx, timeout, fmt.Errorf("failed to read response stream: %v", err))}
+	}
+	return resp, nil, nil
+}
+
+// requestError explains a failed request in terms of the timeout or interrupt that caused it
+func requestError(ctx context.Context, timeout time.Duration, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("request timed out after %s (raise timeout_seconds in the llm config if the API is slow)", timeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("request cancelled")
+	}
+	return err
+}
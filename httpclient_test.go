@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimesOutOnSlowServer(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	config := LLMConfig{APIKey: "test", BaseURL: server.URL, Model: "gpt-test", TimeoutSeconds: 1}
+	start := time.Now()
+	_, err := makeLLMRequest([]ChatMessage{{Role: "user", Content: "diff"}}, config)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s, want it cancelled after the 1s timeout", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Errorf("makeLLMRequest() error = %v, want a timeout", err)
+	}
+	if exitCode(err) != exitAPIError {
+		t.Errorf("exitCode(%v) = %d, want %d", err, exitCode(err), exitAPIError)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	if got := requestTimeout(LLMConfig{}); got != defaultTimeoutSeconds*time.Second {
+		t.Errorf("requestTimeout() = %s, want the default %ds", got, defaultTimeoutSeconds)
+	}
+	if got := requestTimeout(LLMConfig{TimeoutSeconds: 5}); got != 5*time.Second {
+		t.Errorf("requestTimeout() = %s, want 5s", got)
+	}
+}
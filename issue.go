@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Issue holds the parts of a GitHub issue used as prompt context
+type Issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// fetchIssue retrieves an issue's title and body using the gh CLI
+func fetchIssue(number int) (Issue, error) {
+	Log(INFO, "Fetching issue #%d", number)
+	if _, err := exec.LookPath("gh"); err != nil {
+		Log(ERROR, "GitHub CLI (gh) not found")
+		return Issue{}, fmt.Errorf("GitHub CLI (gh) not found. Please install it from https://cli.github.com/")
+	}
+
+	cmd := exec.Command("gh", "issue", "view", strconv.Itoa(number), "--json", "number,title,body")
+	cmd.Dir = repoDir
+	output, err := cmd.Output()
+	if err != nil {
+		Log(ERROR, "Failed to fetch issue #%d: %v", number, err)
+		return Issue{}, fmt.Errorf("failed to fetch issue #%d: %v", number, err)
+	}
+
+	var issue Issue
+	if err := json.Unmarshal(output, &issue); err != nil {
+		Log(ERROR, "Failed to parse issue #%d: %v", number, err)
+		return Issue{}, fmt.Errorf("failed to parse issue #%d: %v", number, err)
+	}
+	Log(DEBUG, "Fetched issue #%d: %s (%d chars of body)", issue.Number, issue.Title, len(issue.Body))
+	return issue, nil
+}
+
+// closesTrailer is the trailer added by -close-issue. GitHub closes the issue when the commit
+// reaches the default branch, and with the colon git sees it as a trailer.
+func closesTrailer(number int) string {
+	return fmt.Sprintf("Closes: #%d", number)
+}
+
+// issueContext formats an issue as additional prompt context
+func issueContext(issue Issue) string {
+	return fmt.Sprintf("This change addresses issue #%d: %s\n\nIssue description:\n%s", issue.Number, issue.Title, issue.Body)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// messageLimitViolation describes how a message exceeds the configured body line and
+// character limits, or returns an empty string if it is within them
+func messageLimitViolation(message string, maxBodyLines int, maxMessageChars int) string {
+	var problems []string
+	_, body := splitTitleAndBody(message)
+	if maxBodyLines > 0 && body != "" {
+		if lines := len(strings.Split(body, "\n")); lines > maxBodyLines {
+			problems = append(problems, fmt.Sprintf("the body has %d lines but must have at most %d", lines, maxBodyLines))
+		}
+	}
+	if maxMessageChars > 0 {
+		if chars := len([]rune(message)); chars > maxMessageChars {
+			problems = append(problems, fmt.Sprintf("the message has %d characters but must have at most %d", chars, maxMessageChars))
+		}
+	}
+	return strings.Join(problems, " and ")
+}
+
+// truncateMessage cuts a message down to the body line and character limits,
+// preferring to cut at a line boundary
+func truncateMessage(message string, maxBodyLines int, maxMessageChars int) string {
+	subject, body := splitTitleAndBody(message)
+	if maxBodyLines > 0 && body != "" {
+		lines := strings.Split(body, "\n")
+		if len(lines) > maxBodyLines {
+			body = strings.TrimSpace(strings.Join(lines[:maxBodyLines], "\n"))
+		}
+	}
+	message = subject
+	if body != "" {
+		message += "\n\n" + body
+	}
+
+	if runes := []rune(message); maxMessageChars > 0 && len(runes) > maxMessageChars {
+		cut := string(runes[:maxMessageChars])
+		if idx := strings.LastIndex(cut, "\n"); idx > len(subject) {
+			cut = cut[:idx]
+		}
+		message = strings.TrimSpace(cut)
+	}
+	return message
+}
+
+// enforceMessageLimits asks the LLM once for a more concise message if the message exceeds
+// the configured limits, and truncates it with a warning if it is still too long. A final
+// trailer block is kept as it is, and the rest of the message must fit in what it leaves.
+func enforceMessageLimits(message string, config Config) string {
+	if content, trailers := splitTrailers(message); trailers != "" {
+		limits := config
+		if limits.MaxBodyLines > 0 {
+			// The trailers and the blank line before them
+			limits.MaxBodyLines = maxInt(limits.MaxBodyLines-len(strings.Split(trailers, "\n"))-1, 1)
+		}
+		if limits.MaxMessageChars > 0 {
+			limits.MaxMessageChars = maxInt(limits.MaxMessageChars-len([]rune(trailers))-2, 1)
+		}
+		return enforceMessageLimits(content, limits) + "\n\n" + trailers
+	}
+
+	violation := messageLimitViolation(message, config.MaxBodyLines, config.MaxMessageChars)
+	if violation == "" {
+		return message
+	}
+
+	Log(INFO, "Generated message is too long (%s), asking for a more concise version", violation)
+	shorter, err := ShortenMessage(message, config.LLM, violation)
+	if err != nil {
+		Log(WARN, "Failed to shorten message: %v", err)
+	} else {
+		message = trimFirstLine(shorter, config.FirstLineLimit)
+		violation = messageLimitViolation(message, config.MaxBodyLines, config.MaxMessageChars)
+		if violation == "" {
+			return message
+		}
+	}
+
+	Log(WARN, "Message still exceeds limits (%s), truncating", violation)
+	fmt.Printf("Warning: the generated message exceeded the configured limits (%s) and was truncated.\n", violation)
+	return truncateMessage(message, config.MaxBodyLines, config.MaxMessageChars)
+}
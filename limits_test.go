@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnforceMessageLimitsKeepsTrailers(t *testing.T) {
+	message := "Add retries\n\n" + strings.Repeat("Explain the change in detail.\n", 5) +
+		"\nChangelog: Requests are retried on timeouts\nCloses: #12"
+	// The missing replay file makes the request for a shorter message fail, so it's truncated
+	config := Config{MaxMessageChars: 140, LLM: LLMConfig{ReplayFile: "/nonexistent"}}
+
+	got := enforceMessageLimits(message, config)
+	if len([]rune(got)) > config.MaxMessageChars {
+		t.Errorf("message has %d characters, want at most %d:\n%s", len([]rune(got)), config.MaxMessageChars, got)
+	}
+	if _, trailers := splitTrailers(got); trailers != "Changelog: Requests are retried on timeouts\nCloses: #12" {
+		t.Errorf("trailers = %q, want both kept as one block", trailers)
+	}
+}
+
+func TestAppendTrailerJoinsTrailerBlock(t *testing.T) {
+	message := appendTrailer("Fix login\n\nChangelog: Login works again", closesTrailer(7))
+	if want := "Fix login\n\nChangelog: Login works again\nCloses: #7"; message != want {
+		t.Errorf("appendTrailer() = %q, want %q", message, want)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Line ending settings for the message file opened in the editor
+const (
+	LineEndingsAuto = "auto" // CRLF on Windows, LF elsewhere
+	LineEndingsLF   = "lf"
+	LineEndingsCRLF = "crlf"
+)
+
+// editorLineEnding returns the line ending to write the message file with
+func editorLineEnding(setting string) string {
+	switch setting {
+	case LineEndingsCRLF:
+		return "\r\n"
+	case LineEndingsLF:
+		return "\n"
+	}
+	if runtime.GOOS == "windows" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// toLineEndings converts a message to the configured line endings
+func toLineEndings(message string, setting string) string {
+	message = normalizeLineEndings(message)
+	if ending := editorLineEnding(setting); ending != "\n" {
+		message = strings.ReplaceAll(message, "\n", ending)
+	}
+	return message
+}
+
+// normalizeLineEndings converts CRLF and lone CR line endings to LF, as git expects
+func normalizeLineEndings(message string) string {
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	return strings.ReplaceAll(message, "\r", "\n")
+}
+
+// normalizeMessageFile rewrites an edited message file with LF line endings
+func normalizeMessageFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read message file: %v", err)
+	}
+	normalized := normalizeLineEndings(string(content))
+	if normalized == string(content) {
+		return nil
+	}
+	Log(DEBUG, "Normalizing line endings of %s to LF", path)
+	if err := os.WriteFile(path, []byte(normalized), 0644); err != nil {
+		return fmt.Errorf("failed to write message file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// readMessageToLint reads a commit message from a file, or from stdin when path is "-".
+// Comment lines are dropped, as git does when it cleans up a message.
+func readMessageToLint(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		Log(DEBUG, "Reading message to lint from stdin")
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		Log(DEBUG, "Reading message to lint from: %s", path)
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		Log(ERROR, "Failed to read message: %v", err)
+		return "", fmt.Errorf("failed to read message: %v", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// lintMessage checks a commit message against the configured conventions and returns
+// a description of each problem found
+func lintMessage(message string, config Config, style string) []string {
+	var problems []string
+	if strings.TrimSpace(message) == "" {
+		return []string{"message is empty"}
+	}
+
+	lines := strings.Split(message, "\n")
+	subject := lines[0]
+
+	if config.FirstLineLimit > 0 && len([]rune(subject)) > config.FirstLineLimit {
+		problems = append(problems, fmt.Sprintf("subject line is %d characters long (limit %d)", len([]rune(subject)), config.FirstLineLimit))
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		problems = append(problems, "missing blank line between the subject and the body")
+	}
+	if config.BodyLineLimit > 0 {
+		for i, line := range lines[1:] {
+			if len([]rune(line)) > config.BodyLineLimit {
+				problems = append(problems, fmt.Sprintf("line %d is %d characters long (limit %d)", i+2, len([]rune(line)), config.BodyLineLimit))
+			}
+		}
+	}
+	if style == StyleConventional && !conventionalSubjectPattern.MatchString(subject) {
+		problems = append(problems, "subject does not follow Conventional Commits (<type>(<scope>): <description>)")
+	}
+	for _, word := range config.BannedWords {
+		pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`)
+		if pattern.MatchString(message) {
+			problems = append(problems, fmt.Sprintf("message contains banned word %q", word))
+		}
+	}
+
+	Log(DEBUG, "Lint found %d problems", len(problems))
+	return problems
+}
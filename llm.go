@@ -11,15 +11,45 @@ import (
 	"os"
 	"bufio"
 	"regexp"
+	"path/filepath"
+	"sort"
+	"time"
 )
 
-// LLMConfig holds configuration for the OpenAI API
+// LLMConfig holds configuration for the LLM API
 type LLMConfig struct {
+	Provider        string  `json:"provider"` // LLM API: openai (default), anthropic or ollama
 	APIKey          string  `json:"api_key"`
 	Model           string  `json:"model"`
 	Temperature     float64 `json:"temperature"`
 	MaxTokens       int     `json:"max_tokens"`
-	EnableQuestions bool    `json:"enable_questions"`
+	BaseURL         string  `json:"base_url"` // API base URL, e.g. for OpenAI-compatible providers
+	EnableQuestions bool    `json:"enable_questions"` // Deprecated: sets both enable_commit_questions and enable_pr_questions
+	EnableCommitQuestions bool `json:"enable_commit_questions"` // Let the LLM ask clarifying questions for commit messages (currently only -pr-style commits)
+	EnablePRQuestions     bool `json:"enable_pr_questions"`     // Let the LLM ask clarifying questions when generating PR descriptions
+	MaxQuestions          int  `json:"max_questions"`           // Most clarifying questions the LLM may ask at once (default 3)
+	EnableCache     bool    `json:"enable_cache"` // Reuse previous generations for identical input
+	TwoPhase        bool    `json:"two_phase"`    // Generate the commit body first, then the subject (doubles API calls)
+	TemperatureStep float64 `json:"temperature_step"` // Temperature increase for each regeneration, capped at 1.0
+	TimeoutSeconds  int     `json:"timeout_seconds"`  // Timeout for each API request (default 60)
+	EditPrompt      bool    `json:"-"` // Set by the -edit-prompt flag, not the config file
+	RecordFile      string  `json:"-"` // Set by the -record flag: save LLM responses to this file
+	ReplayFile      string  `json:"-"` // Set by the -replay flag: return responses from this file instead of calling the API
+	ExtraContext    []string `json:"-"` // Additional context gathered at runtime, appended to the user message
+	ExtraInstructions []string `json:"-"` // Additional instructions determined at runtime, appended to the system prompt
+	ShowTokenBreakdown bool `json:"-"` // Print estimated tokens per prompt section (set in dry-run mode)
+	FewShotExamples []FewShotExample `json:"-"` // Example diffs and messages shown before the diff, loaded from few_shot_file
+	StreamOutput    bool     `json:"-"` // Print the response to stdout as it arrives (set for PRs when stdout is a terminal)
+	UsageTotal      *Usage   `json:"-"` // If set, accumulates the token usage of the calls made with this config
+	Answers         []PresetAnswer `json:"-"` // Answers to clarifying questions given with -answers and -answer, used instead of asking
+}
+
+// disableQuestions turns off clarifying questions, e.g. for generations that run concurrently
+// and can't share stdin
+func (c *LLMConfig) disableQuestions() {
+	c.EnableQuestions = false
+	c.EnableCommitQuestions = false
+	c.EnablePRQuestions = false
 }
 
 // ChatMessage represents a message in the OpenAI chat format
@@ -28,19 +58,25 @@ type ChatMessage struct {
 	Content string `json:"content"`
 }
 
-// ChatRequest represents the request body for OpenAI chat completions API
+// ChatRequest represents the request body for OpenAI chat completions API.
+// Only one of MaxTokens and MaxCompletionTokens is set, depending on the model.
 type ChatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []ChatMessage `json:"messages"`
-	Temperature float64       `json:"temperature"`
-	MaxTokens   int           `json:"max_tokens"`
+	Model               string         `json:"model"`
+	Messages            []ChatMessage  `json:"messages"`
+	Temperature         float64        `json:"temperature"`
+	MaxTokens           int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int            `json:"max_completion_tokens,omitempty"`
+	Stream              bool           `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // ChatResponse represents the response from OpenAI chat completions API
 type ChatResponse struct {
 	Choices []struct {
-		Message ChatMessage `json:"message"`
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -56,9 +92,10 @@ type QuestionResponse struct {
 func NewLLMConfig() LLMConfig {
 	// Default values
 	config := LLMConfig{
-		Model:       "gpt-4",
-		Temperature: 0.7,
-		MaxTokens:   1000,
+		Model:        "gpt-4",
+		Temperature:  0.7,
+		MaxTokens:    1000,
+		MaxQuestions: defaultMaxQuestions,
 	}
 	// First try to get API key directly from environment
 	config.APIKey = os.Getenv("OPENAI_KEY")
@@ -69,26 +106,25 @@ func NewLLMConfig() LLMConfig {
 			// Successfully loaded .env file, try again
 			config.APIKey = os.Getenv("OPENAI_KEY")
 		} else {
-			// Print a helpful message about the missing API key
-			fmt.Println("Note: Could not load .env file:", err)
+			Log(DEBUG, "Could not load .env file: %v", err)
 		}
 	}
 	
 	// Debug output to verify the API key status
 	if config.APIKey == "" {
-		fmt.Println("Warning: OPENAI_KEY environment variable not found")
-		fmt.Println("Make sure it's set in your environment or .env file")
+		Log(WARN, "OPENAI_KEY not found; make sure it's set in your environment or .env file")
 	} else {
-		fmt.Println("OPENAI_KEY found with length:", len(config.APIKey))
+		registerSecret(config.APIKey)
+		Log(DEBUG, "OPENAI_KEY found: %s", maskSecret(config.APIKey))
 	}
 	
 	return config
 }
 
-// GenerateCommitMessage uses the OpenAI API to generate a commit message based on the diff
+// GenerateCommitMessage uses the configured LLM provider to generate a commit message based on the diff
 func GenerateCommitMessage(diff string, config LLMConfig, template string) (string, error) {
-	if config.APIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not found. Set the OPENAI_KEY environment variable")
+	if err := requireAPIKey(config); err != nil {
+		return "", err
 	}
 
 	// Create the system prompt using the template
@@ -112,101 +148,230 @@ func GenerateCommitMessage(diff string, config LLMConfig, template string) (stri
 	The rest of the commit message should be an informative description of the changes you made.
 	Use the following template format for your response:
 	%s`, template)
+	systemPrompt = withExtraInstructions(systemPrompt, config.ExtraInstructions)
 
 	// Prepare the request
-	messages := []ChatMessage{
-		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: fmt.Sprintf("Here is the git diff:\n\n%s", diff)},
+	messages := []ChatMessage{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, fewShotMessages(config.FewShotExamples)...)
+	userContent := withExtraContext(fmt.Sprintf("Here is the git diff:\n\n%s", diff), config.ExtraContext)
+	messages = append(messages, ChatMessage{Role: "user", Content: userContent})
+
+	if config.ShowTokenBreakdown {
+		// Few-shot examples count as other user content
+		printTokenBreakdown(systemPrompt, template, diff, "Diff", formatFewShotExamples(config.FewShotExamples)+userContent)
 	}
 
-	requestBody := ChatRequest{
-		Model:       config.Model,
-		Messages:    messages,
-		Temperature: config.Temperature,
-		MaxTokens:   config.MaxTokens,
+	if config.EditPrompt {
+		var err error
+		messages, err = editPromptMessages(messages)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	if config.TwoPhase {
+		return generateTwoPhase(messages, config)
+	}
+
+	response, err := makeLLMRequest(messages, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return "", err
 	}
 
-	// Make the API request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	// Return the generated commit message
+	return strings.TrimSpace(response), nil
+}
+
+// generateTwoPhase generates the commit body first and then, in a second call, a subject line
+// written with the body in hand. Asking for both at once sometimes yields a weak subject.
+func generateTwoPhase(messages []ChatMessage, config LLMConfig) (string, error) {
+	Log(INFO, "Generating commit message in two phases")
+
+	bodyMessages := append(append([]ChatMessage{}, messages...), ChatMessage{
+		Role:    "user",
+		Content: "Write only the body of the commit message, without the first line. Do not include a subject line.",
+	})
+	body, err := makeLLMRequest(bodyMessages, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", err
+	}
+	body = strings.TrimSpace(body)
+	Log(DEBUG, "Generated commit body (%d chars)", len(body))
+
+	subjectMessages := append(append([]ChatMessage{}, messages...),
+		ChatMessage{Role: "assistant", Content: body},
+		ChatMessage{
+			Role: "user",
+			Content: "That is the body of the commit message. Now write only the first line of the commit message, " +
+				"following the first line format described earlier, so that it accurately summarizes the diff and the body. " +
+				"Respond with the first line only.",
+		},
+	)
+	subject, err := makeLLMRequest(subjectMessages, config)
+	if err != nil {
+		return "", err
 	}
+	subject, _ = splitTitleAndBody(subject)
+	Log(DEBUG, "Generated commit subject: %s", subject)
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.APIKey))
+	return strings.TrimSpace(subject + "\n\n" + body), nil
+}
+
+// ShortenMessage asks the LLM to make a commit message more concise so it fits the given limits
+func ShortenMessage(message string, config LLMConfig, violation string) (string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return "", err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	messages := []ChatMessage{
+		{Role: "system", Content: `You are a professional software engineer editing a commit message to make it more concise.
+	Keep the first line exactly as it is. Keep the same format and the most important information,
+	and remove repetition and minor details. Respond with the edited commit message only.`},
+		{Role: "user", Content: fmt.Sprintf("This commit message is too long: %s.\n\n%s", violation, message)},
+	}
+
+	response, err := makeLLMRequest(messages, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return "", err
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// AddFileMentions asks the LLM to revise a commit message so it mentions the given files
+func AddFileMentions(message string, config LLMConfig, files []string) (string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	messages := []ChatMessage{
+		{Role: "system", Content: `You are a professional software engineer editing a commit message.
+	Keep the first line exactly as it is and keep the same format. Revise the body so that it mentions
+	each of the listed files by name, saying briefly how it changed. Respond with the edited commit message only.`},
+		{Role: "user", Content: fmt.Sprintf("Files to mention: %s\n\n%s", strings.Join(files, ", "), message)},
+	}
+
+	response, err := makeLLMRequest(messages, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return "", err
 	}
+	return strings.TrimSpace(response), nil
+}
 
-	var chatResponse ChatResponse
-	if err := json.Unmarshal(body, &chatResponse); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+// GenerateChangelogEntry asks the LLM for a one-line changelog entry describing the
+// user-facing impact of a commit
+func GenerateChangelogEntry(message string, config LLMConfig) (string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return "", err
 	}
 
-	// Check for API errors
-	if chatResponse.Error != nil {
-		return "", fmt.Errorf("API error: %s", chatResponse.Error.Message)
+	messages := []ChatMessage{
+		{Role: "system", Content: `You write changelog entries for release notes.
+	Given a commit message, describe its user-facing impact in one short line, written for users
+	rather than developers and different from the commit subject. If the change has no user-facing
+	impact, respond with "none". Respond with the entry only, without a prefix or bullet.`},
+		{Role: "user", Content: message},
 	}
 
-	if len(chatResponse.Choices) == 0 {
-		return "", fmt.Errorf("no response from API")
+	response, err := makeLLMRequest(messages, config)
+	if err != nil {
+		return "", err
 	}
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(response), "\n", 2)[0]), nil
+}
 
-	// Return the generated commit message
-	return strings.TrimSpace(chatResponse.Choices[0].Message.Content), nil
+// PRInput is what a PR-style message is generated from
+type PRInput struct {
+	Description string // How the system prompt refers to the input, e.g. "a list of commit messages from the branch"
+	Intro       string // Introduces the content in the user message
+	Label       string // Row label in the token breakdown
+	Content     string
+	Questions   bool // Whether the LLM may ask clarifying questions
 }
 
-// GeneratePRMessage uses the OpenAI API to generate a PR message based on commit messages
+// GeneratePRMessage uses the configured LLM provider to generate a PR message based on commit messages
 func GeneratePRMessage(commits string, config LLMConfig, template string) (string, error) {
-	if config.APIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not found. Set the OPENAI_KEY environment variable")
+	return generatePRStyleMessage(PRInput{
+		Description: "a list of commit messages from the branch",
+		Intro:       "Here are the commit messages from the branch:",
+		Label:       "Commit messages",
+		Content:     commits,
+		Questions:   config.EnablePRQuestions,
+	}, config, template)
+}
+
+// GenerateSquashMessage generates a PR-style message from the staged diff, for a single commit
+// that makes up a whole pull request
+func GenerateSquashMessage(diff string, config LLMConfig, template string) (string, error) {
+	return generatePRStyleMessage(PRInput{
+		Description: "the diff of the single commit that makes up the whole pull request",
+		Intro:       "Here is the diff of the changes:",
+		Label:       "Diff",
+		Content:     diff,
+		Questions:   config.EnableCommitQuestions,
+	}, config, template)
+}
+
+// generatePRStyleMessage generates a message following a PR template from the given input
+func generatePRStyleMessage(input PRInput, config LLMConfig, template string) (string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return "", err
 	}
 
 	// Create the system prompt using the template
 	systemPrompt := fmt.Sprintf(
 	`You are a professional software engineer who has finished a feature branch and is creating a pull request. 
-	You will be given a list of commit messages from the branch and a PR template. Use the template to generate a 
+	You will be given %s and a PR template. Use the template to generate a 
 	comprehensive PR description. The PR description should clearly explain the changes, their purpose, and any 
 	important implementation details.Do not include any other texts about testing, a human who will review 
 	your PR message will fill that part out. IMPORTANT: You MUST include the ENTIRE template in your response, 
 	including ALL sections at the end. %s Use the following template format for your response:
-	%s`, getQuestionsPrompt(config.EnableQuestions), template)
+	%s`, input.Description, getQuestionsPrompt(input.Questions, config.MaxQuestions), template)
+	systemPrompt = withExtraInstructions(systemPrompt, config.ExtraInstructions)
 
 	// Prepare the request
+	userContent := withExtraContext(fmt.Sprintf("%s\n\n%s", input.Intro, input.Content), config.ExtraContext)
 	messages := []ChatMessage{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: fmt.Sprintf("Here are the commit messages from the branch:\n\n%s", commits)},
+		{Role: "user", Content: userContent},
+	}
+
+	if config.ShowTokenBreakdown {
+		printTokenBreakdown(systemPrompt, template, input.Content, input.Label, userContent)
+	}
+
+	if config.EditPrompt {
+		var err error
+		messages, err = editPromptMessages(messages)
+		if err != nil {
+			return "", err
+		}
+		// Keep the edited system prompt for the follow-up request after questions
+		for _, msg := range messages {
+			if msg.Role == "system" {
+				systemPrompt = msg.Content
+				break
+			}
+		}
 	}
 
-	fmt.Println("Generating PR description based on commit messages...")
+	fmt.Printf("Generating PR description based on %s...\n", strings.ToLower(input.Label))
 	
-	// First API call to generate PR message or ask questions
-	response, err := makeOpenAIRequest(messages, config)
+	// First API call to generate PR message or ask questions. A response that may be
+	// questions isn't streamed, as the raw JSON would be printed.
+	firstConfig := config
+	firstConfig.StreamOutput = config.StreamOutput && !input.Questions
+	response, err := makeLLMRequest(messages, firstConfig)
 	if err != nil {
 		return "", err
 	}
 
 	// Check if questions are enabled and if the response contains questions
-	questionResponses, hasQuestions := extractQuestions(response)
-	if hasQuestions && config.EnableQuestions {
+	questionResponses, hasQuestions := extractQuestions(response, config.MaxQuestions)
+	if hasQuestions && input.Questions {
 		fmt.Printf("The AI has %d questions to help create a better PR description.\n", len(questionResponses))
 		
-		// Get answers from the user
-		questionResponses = askUserQuestions(questionResponses)
+		// Get answers from the presets or the user
+		questionResponses = answerQuestions(questionResponses, config.Answers)
 		
 		// Check if any questions were answered
 		anyAnswered := false
@@ -224,7 +389,7 @@ func GeneratePRMessage(commits string, config LLMConfig, template string) (strin
 			// so we need to include all messages in the new request
 			newMessages := []ChatMessage{
 				{Role: "system", Content: systemPrompt},
-				{Role: "user", Content: fmt.Sprintf("Here are the commit messages from the branch:\n\n%s", commits)},
+				{Role: "user", Content: userContent},
 				{Role: "assistant", Content: "I need some additional information to write a better PR description."},
 			}
 			
@@ -247,7 +412,7 @@ func GeneratePRMessage(commits string, config LLMConfig, template string) (strin
 			fmt.Println("Generating final PR description with your additional context...")
 			
 			// Make a second API call with the additional context
-			response, err = makeOpenAIRequest(newMessages, config)
+			response, err = makeLLMRequest(newMessages, config)
 			if err != nil {
 				return "", err
 			}
@@ -262,53 +427,233 @@ func GeneratePRMessage(commits string, config LLMConfig, template string) (strin
 	return strings.TrimSpace(response), nil
 }
 
+// withExtraInstructions appends any additional instructions to the system prompt
+func withExtraInstructions(systemPrompt string, instructions []string) string {
+	for _, instruction := range instructions {
+		systemPrompt += "\n\n\t" + instruction
+	}
+	return systemPrompt
+}
+
+// withExtraContext appends any additional context to the user message content
+func withExtraContext(content string, extraContext []string) string {
+	if len(extraContext) == 0 {
+		return content
+	}
+	return content + "\n\nAdditional context:\n" + strings.Join(extraContext, "\n")
+}
+
+// askIntent asks the user for the goal of the change before generating a message
+func askIntent() string {
+	fmt.Print("What's the goal of this change? (press Enter to skip): ")
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	Log(DEBUG, "Intent provided: %v", answer != "")
+	return answer
+}
+
+// defaultMaxQuestions is how many clarifying questions the LLM may ask when max_questions isn't set
+const defaultMaxQuestions = 3
+
 // getQuestionsPrompt returns the prompt for questions based on whether the feature is enabled
-func getQuestionsPrompt(enableQuestions bool) string {
+func getQuestionsPrompt(enableQuestions bool, maxQuestions int) string {
 	if enableQuestions {
-		return `
-	If you need additional information to write a more informative PR description, you can ask up to 3 questions.
+		examples := make([]string, maxQuestions)
+		for i := range examples {
+			examples[i] = fmt.Sprintf("%q", fmt.Sprintf("question %d", i+1))
+		}
+		noun := "questions"
+		if maxQuestions == 1 {
+			noun = "question"
+		}
+		return fmt.Sprintf(`
+	If you need additional information to write a more informative PR description, you can ask up to %d %s.
 	To ask questions, respond with a JSON object in the following format:
-	{"questions": ["question 1", "question 2", "question 3"]}
+	{"questions": [%s]}
 	
 	Only ask questions if you genuinely need more context to write a better PR description. Don't ask questions in most cases.
-	`
+	`, maxQuestions, noun, strings.Join(examples, ", "))
 	}
 	return ""
 }
 
-// makeOpenAIRequest makes a request to the OpenAI API and returns the response content
-func makeOpenAIRequest(messages []ChatMessage, config LLMConfig) (string, error) {
+// maxCompletionTokensModels lists the model prefixes that reject max_tokens in favor of max_completion_tokens
+var maxCompletionTokensModels = []string{"o1", "o3", "o4", "gpt-5"}
+
+// usesMaxCompletionTokens reports whether the model expects max_completion_tokens instead of max_tokens
+func usesMaxCompletionTokens(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range maxCompletionTokensModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newChatRequest builds the request body, mapping MaxTokens onto the parameter name the model expects
+func newChatRequest(messages []ChatMessage, config LLMConfig, useCompletionTokens bool) ChatRequest {
 	requestBody := ChatRequest{
 		Model:       config.Model,
 		Messages:    messages,
 		Temperature: config.Temperature,
-		MaxTokens:   config.MaxTokens,
 	}
+	if useCompletionTokens {
+		requestBody.MaxCompletionTokens = config.MaxTokens
+	} else {
+		requestBody.MaxTokens = config.MaxTokens
+	}
+	return requestBody
+}
+
+// isTokenParamError reports whether an API error complains about the token limit parameter name
+func isTokenParamError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "API error") && strings.Contains(msg, "max_tokens") && strings.Contains(msg, "max_completion_tokens")
+}
+
+// maxRegenerationTemperature caps the temperature reached by repeated regenerations
+const maxRegenerationTemperature = 1.0
+
+// regenerationTemperature returns the temperature to use for the given regeneration attempt
+// (0 for the first generation). Each attempt adds the configured step so successive
+// regenerations get progressively more varied; a fresh run starts again from the base.
+func regenerationTemperature(config LLMConfig, attempt int) float64 {
+	if config.Temperature >= maxRegenerationTemperature {
+		return config.Temperature
+	}
+	temperature := config.Temperature + config.TemperatureStep*float64(attempt)
+	if temperature > maxRegenerationTemperature {
+		temperature = maxRegenerationTemperature
+	}
+	Log(DEBUG, "Temperature for regeneration attempt %d: %.2f", attempt, temperature)
+	return temperature
+}
 
+// defaultBaseURL is the OpenAI API base URL used when base_url is not configured
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// apiURL joins the configured base URL and an API path
+func apiURL(config LLMConfig, path string) string {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		switch config.Provider {
+		case ProviderAnthropic:
+			baseURL = defaultAnthropicBaseURL
+		case ProviderOllama:
+			baseURL = defaultOllamaBaseURL
+		default:
+			baseURL = defaultBaseURL
+		}
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
+
+// listModels returns the IDs of the models available to the configured API key
+func listModels(config LLMConfig) ([]string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return nil, err
+	}
+
+	modelsPath := "/models"
+	if config.Provider == ProviderOllama {
+		// Ollama lists its models in the OpenAI format under its OpenAI-compatible API
+		modelsPath = "/v1/models"
+	}
+	url := apiURL(config, modelsPath)
+	Log(INFO, "Listing models from %s", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	setAuthHeaders(req, config)
+
+	_, body, err := doHTTPRequest(req, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var modelsResponse struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(body, &modelsResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if modelsResponse.Error != nil {
+		return nil, &APIError{Err: fmt.Errorf("API error: %s", modelsResponse.Error.Message)}
+	}
+
+	models := make([]string, 0, len(modelsResponse.Data))
+	for _, model := range modelsResponse.Data {
+		models = append(models, model.ID)
+	}
+	sort.Strings(models)
+	Log(DEBUG, "Found %d models", len(models))
+	return models, nil
+}
+
+// checkFinishReason warns when a reply was cut off by the token limit and rejects replies
+// withheld by a content filter, so a refusal or partial text is never committed
+func checkFinishReason(reason string, config LLMConfig) error {
+	switch reason {
+	case "length", "max_tokens":
+		Log(WARN, "The response hit the token limit (max_tokens %d) and may be truncated", config.MaxTokens)
+		fmt.Printf("Warning: the generated message hit the token limit (max_tokens %d) and may be truncated.\n", config.MaxTokens)
+	case "content_filter", "refusal":
+		Log(ERROR, "The response was stopped by the provider (%s)", reason)
+		return fmt.Errorf("the model's response was blocked (%s); nothing was generated", reason)
+	}
+	return nil
+}
+
+// makeLLMRequest sends the messages to the configured provider and returns the response content.
+// Responses are replayed from or recorded to a fixture file when -replay or -record is used.
+func makeLLMRequest(messages []ChatMessage, config LLMConfig) (string, error) {
+	if config.ReplayFile != "" {
+		return replayResponse(config.ReplayFile)
+	}
+
+	provider, err := providerFor(config)
+	if err != nil {
+		return "", err
+	}
+	response, err := provider.Chat(messages, config)
+	if err == nil && config.RecordFile != "" {
+		if err := recordResponse(config.RecordFile, response); err != nil {
+			return "", err
+		}
+	}
+	return response, err
+}
+
+// sendChatRequest sends a chat completions request and returns the response content
+func sendChatRequest(requestBody ChatRequest, config LLMConfig) (string, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
 	// Make the API request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", apiURL(config, "/chat/completions"), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.APIKey))
+	setAuthHeaders(req, config)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
+	release := acquireRequestSlot()
+	defer release()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	_, body, err := doHTTPRequest(req, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return "", err
 	}
 
 	var chatResponse ChatResponse
@@ -318,18 +663,29 @@ func makeOpenAIRequest(messages []ChatMessage, config LLMConfig) (string, error)
 
 	// Check for API errors
 	if chatResponse.Error != nil {
-		return "", fmt.Errorf("API error: %s", chatResponse.Error.Message)
+		return "", &APIError{Err: fmt.Errorf("API error: %s", chatResponse.Error.Message)}
 	}
 
 	if len(chatResponse.Choices) == 0 {
 		return "", fmt.Errorf("no response from API")
 	}
 
+	if err := checkFinishReason(chatResponse.Choices[0].FinishReason, config); err != nil {
+		return "", err
+	}
+
+	var usage Usage
+	if chatResponse.Usage != nil {
+		usage = *chatResponse.Usage
+		Log(DEBUG, "Token usage: %d prompt, %d completion", usage.PromptTokens, usage.CompletionTokens)
+	}
+	recordUsage(config, usage)
+
 	return chatResponse.Choices[0].Message.Content, nil
 }
 
 // extractQuestions checks if the response contains questions and extracts them
-func extractQuestions(response string) ([]QuestionResponse, bool) {
+func extractQuestions(response string, maxQuestions int) ([]QuestionResponse, bool) {
 	// Try to parse the entire response as JSON first
 	var questionsObj struct {
 		Questions []string `json:"questions"`
@@ -338,7 +694,7 @@ func extractQuestions(response string) ([]QuestionResponse, bool) {
 	// If the entire response is valid JSON with questions
 	if err := json.Unmarshal([]byte(response), &questionsObj); err == nil && len(questionsObj.Questions) > 0 {
 		Log(DEBUG, "Found questions in complete JSON response")
-		return convertToQuestionResponses(questionsObj.Questions), true
+		return convertToQuestionResponses(questionsObj.Questions, maxQuestions), true
 	}
 	
 	// If not, try to find JSON object within text using regex
@@ -364,13 +720,12 @@ func extractQuestions(response string) ([]QuestionResponse, bool) {
 		return nil, false
 	}
 	
-	return convertToQuestionResponses(questionsObj.Questions), true
+	return convertToQuestionResponses(questionsObj.Questions, maxQuestions), true
 }
 
 // Helper function to convert string questions to QuestionResponse objects
-func convertToQuestionResponses(questions []string) []QuestionResponse {
-	// Limit the number of questions to 3
-	maxQuestions := 3
+func convertToQuestionResponses(questions []string, maxQuestions int) []QuestionResponse {
+	// Limit the number of questions to the configured maximum
 	if len(questions) > maxQuestions {
 		Log(INFO, "Limiting questions to %d (received %d)", maxQuestions, len(questions))
 		questions = questions[:maxQuestions]
@@ -413,7 +768,11 @@ func askUserQuestions(questions []QuestionResponse) []QuestionResponse {
 		}
 	}
 	
-	// Count how many questions were answered
+	return questions
+}
+
+// printAnswerSummary reports how many of the questions were answered
+func printAnswerSummary(questions []QuestionResponse) {
 	answeredCount := 0
 	for _, q := range questions {
 		if q.Answer != "" {
@@ -428,8 +787,6 @@ func askUserQuestions(questions []QuestionResponse) []QuestionResponse {
 	} else {
 		fmt.Println("\nAll questions answered. Proceeding with full additional context.")
 	}
-	
-	return questions
 }
 
 // formatQuestionsAndAnswers formats the questions and answers for the API request
@@ -494,4 +851,72 @@ func extractPRDescription(response string) string {
 	
 	// If we couldn't extract anything, return an empty string
 	return ""
-} 
\ No newline at end of file
+}
+
+// promptRoleMarker matches the lines that separate messages in the prompt editing file
+var promptRoleMarker = regexp.MustCompile(`^=== (system|user|assistant) ===$`)
+
+// editPromptMessages opens the assembled messages in the editor so the user can
+// tweak them before they are sent, and returns the edited messages
+func editPromptMessages(messages []ChatMessage) ([]ChatMessage, error) {
+	Log(INFO, "Opening assembled prompt for editing")
+	var sb strings.Builder
+	for _, msg := range messages {
+		sb.WriteString(fmt.Sprintf("=== %s ===\n", msg.Role))
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n")
+	}
+
+	promptFile := filepath.Join(tempDir(), fmt.Sprintf("git_prompt_%d.txt", time.Now().Unix()))
+	Log(DEBUG, "Writing prompt to temporary file: %s", promptFile)
+	if err := ioutil.WriteFile(promptFile, []byte(sb.String()), 0600); err != nil {
+		Log(ERROR, "Failed to write prompt file: %v", err)
+		return nil, fmt.Errorf("failed to write prompt file: %v", err)
+	}
+	defer os.Remove(promptFile)
+
+	if err := openInEditor(promptFile); err != nil {
+		return nil, fmt.Errorf("failed to edit prompt: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(promptFile)
+	if err != nil {
+		Log(ERROR, "Failed to read edited prompt: %v", err)
+		return nil, fmt.Errorf("failed to read edited prompt: %v", err)
+	}
+
+	edited := parsePromptMessages(string(data))
+	if len(edited) == 0 {
+		Log(ERROR, "Edited prompt contains no messages")
+		return nil, fmt.Errorf("edited prompt contains no messages; keep at least one '=== user ===' section")
+	}
+	Log(DEBUG, "Parsed %d messages from edited prompt", len(edited))
+	return edited, nil
+}
+
+// parsePromptMessages parses messages written by editPromptMessages back into ChatMessages
+func parsePromptMessages(text string) []ChatMessage {
+	var messages []ChatMessage
+	var current *ChatMessage
+	var content []string
+
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			messages = append(messages, *current)
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if match := promptRoleMarker.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+			flush()
+			current = &ChatMessage{Role: match[1]}
+			content = nil
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+
+	return messages
+}
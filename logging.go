@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +20,9 @@ const (
 
 var logLevel = INFO
 
+// logTimeLayout is the time.Format layout of log timestamps. It can be overridden, e.g. to pin output.
+var logTimeLayout = "2006-01-02 15:04:05"
+
 // SetLogLevel sets the minimum log level to display
 func SetLogLevel(level LogLevel) {
 	logLevel = level
@@ -39,7 +44,45 @@ func Log(level LogLevel, format string, args ...interface{}) {
 		levelStr = "ERROR"
 	}
 	
-	timestamp := time.Now().Format("2025-03-09 15:04:05")
-	message := fmt.Sprintf(format, args...)
+	timestamp := time.Now().Format(logTimeLayout)
+	message := redactSecrets(fmt.Sprintf(format, args...))
 	fmt.Fprintf(os.Stderr, "[%s] %s: %s\n", timestamp, levelStr, message)
-} 
\ No newline at end of file
+}
+
+// secrets are values that must never appear in log output, such as API keys
+var (
+	secretsMu sync.Mutex
+	secrets   []string
+)
+
+// minSecretLength is the shortest secret masked in log output. Shorter values, such as
+// placeholder keys for local servers, would mask unrelated text.
+const minSecretLength = 8
+
+// registerSecret makes Log mask every occurrence of secret
+func registerSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, secret)
+}
+
+// redactSecrets masks every registered secret in a log message
+func redactSecrets(message string) string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, secret := range secrets {
+		message = strings.ReplaceAll(message, secret, maskSecret(secret))
+	}
+	return message
+}
+
+// maskSecret hides a secret for display, showing only its last 4 characters
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStderr returns what f writes to stderr
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	previous := os.Stderr
+	os.Stderr = file
+	defer func() { os.Stderr = previous }()
+
+	f()
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+var logLinePattern = regexp.MustCompile(`^\[(.+)\] (\w+): (.*)\n$`)
+
+func TestLogTimestamp(t *testing.T) {
+	before := time.Now().Truncate(time.Second)
+	output := captureStderr(t, func() { Log(ERROR, "disk %s", "full") })
+	after := time.Now()
+
+	match := logLinePattern.FindStringSubmatch(output)
+	if match == nil {
+		t.Fatalf("log line = %q, want [timestamp] LEVEL: message", output)
+	}
+	if match[2] != "ERROR" || match[3] != "disk full" {
+		t.Errorf("log line = %q, want level ERROR and message %q", output, "disk full")
+	}
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", match[1], time.Local)
+	if err != nil {
+		t.Fatalf("timestamp %q doesn't parse: %v", match[1], err)
+	}
+	if timestamp.Before(before) || timestamp.After(after) {
+		t.Errorf("timestamp %s isn't between %s and %s", timestamp, before, after)
+	}
+}
+
+func TestLogTimeLayoutOverride(t *testing.T) {
+	previous := logTimeLayout
+	logTimeLayout = "fixed"
+	defer func() { logTimeLayout = previous }()
+
+	if output := captureStderr(t, func() { Log(WARN, "hello") }); output != "[fixed] WARN: hello\n" {
+		t.Errorf("log line = %q, want %q", output, "[fixed] WARN: hello\n")
+	}
+}
+
+func TestLogLevelFilters(t *testing.T) {
+	previous := logLevel
+	SetLogLevel(WARN)
+	defer SetLogLevel(previous)
+
+	if output := captureStderr(t, func() { Log(INFO, "hidden") }); output != "" {
+		t.Errorf("INFO message logged at WARN level: %q", output)
+	}
+}
+
+func TestAPIKeyNeverLogged(t *testing.T) {
+	const key = "sk-test-0123456789abcdef"
+	t.Setenv("OPENAI_KEY", key)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "Incorrect API key provided: ` + key + `"}}`))
+	}))
+	defer server.Close()
+
+	previous := logLevel
+	SetLogLevel(DEBUG)
+	defer SetLogLevel(previous)
+
+	var err error
+	output := captureStderr(t, func() {
+		var config Config
+		config, err = parseConfig([]byte(`{"llm": {"base_url": "` + server.URL + `"}}`))
+		if err != nil {
+			return
+		}
+		_, err = makeLLMRequest([]ChatMessage{{Role: "user", Content: "diff"}}, config.LLM)
+		Log(ERROR, "Failed: %v", err)
+	})
+	if err == nil {
+		t.Fatal("makeLLMRequest() succeeded, want the API's error")
+	}
+	if strings.Contains(output, key) {
+		t.Errorf("log output contains the API key:\n%s", output)
+	}
+	if strings.Contains(err.Error(), key) {
+		t.Errorf("error %q contains the API key", err)
+	}
+	if !strings.Contains(err.Error(), "Incorrect API key provided: ****cdef") {
+		t.Errorf("error = %q, want the API's message with the key masked", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -12,10 +13,59 @@ import (
 func main() {
 	// Define command-line flags
 	generatePR := flag.Bool("pr", false, "Generate a PR message and prepare for PR creation")
-	targetBranch := flag.String("target", "master", "Target branch for PR (default: master)")
+	targetBranch := flag.String("target", "", "Target branch for PR (default: default_target_branch from the config, then the remote's default branch, then main or master, whichever exists, or master if neither does)")
+	fetchTarget := flag.Bool("fetch", false, "Fetch the target branch from origin before collecting the PR's commits (with -pr)")
+	updatePR := flag.Bool("update-pr", false, "Regenerate the description of the branch's open PR taking its review comments into account, and update it (with -pr)")
+	draft := flag.Bool("draft", false, "Create the PR as a draft")
+	var reviewers, assignees, labels stringListFlag
+	flag.Var(&reviewers, "reviewer", "Request a review from this user or team on the new PR (repeatable or comma-separated; replaces pr_reviewers)")
+	flag.Var(&assignees, "assignee", "Assign this user to the new PR (repeatable or comma-separated; replaces pr_assignees)")
+	flag.Var(&labels, "label", "Add this label to the new PR (repeatable or comma-separated; replaces pr_labels)")
+	suggestLabels := flag.Bool("suggest-labels", false, "Have the LLM propose the PR title and body as JSON together with labels from allowed_labels, and add the labels to the new PR")
 	skipCreate := flag.Bool("skip-create", false, "Skip PR creation on GitHub (only generate message)")
-	configPath := flag.String("config", "", "Path to config file (default: search in standard locations)")
+	includeDiffStat := flag.Bool("include-diffstat", false, "Append the diffstat against the target branch to the PR body")
+	titleFromBranch := flag.Bool("title-from-branch", false, "Derive the PR title from the branch name, e.g. feature/TEAM-123-add-thing becomes \"Add thing (TEAM-123)\"")
+	useFill := flag.Bool("fill", false, "Let gh derive the PR title and body from commits (--fill) instead of using the generated title and body")
+	var configPaths configPathList
+	flag.Var(&configPaths, "config", "Path to config file (default: search in standard locations). Repeat to layer files, later ones overriding earlier ones")
 	dryRun := flag.Bool("dry-run", false, "Generate message but don't commit or create PR")
+	editPrompt := flag.Bool("edit-prompt", false, "Open the assembled prompt in the editor before sending it to the LLM")
+	recordFile := flag.String("record", "", "Record the LLM responses of this run to a file")
+	replayFile := flag.String("replay", "", "Replay LLM responses from a file recorded with -record instead of calling the API")
+	askIntentFlag := flag.Bool("ask-intent", false, "Ask for the goal of the change before generating and include it in the prompt")
+	answersFile := flag.String("answers", "", "Answer the LLM's clarifying questions from a JSON file mapping question patterns to answers instead of asking")
+	var answerFlags answerListFlag
+	flag.Var(&answerFlags, "answer", "Answer clarifying questions containing pattern (case-insensitive) with answer, given as pattern=answer (repeatable; overrides -answers)")
+	repoDirFlag := flag.String("repo-dir", "", "Run git commands in this repository or worktree instead of the current directory")
+	issueNumber := flag.Int("issue", 0, "GitHub issue number to include as context (fetched with gh)")
+	closeIssue := flag.Bool("close-issue", false, "Add a 'Closes: #<number>' trailer for the issue given with -issue")
+	lintFile := flag.String("lint-message", "", "Validate a commit message from a file (or - for stdin) instead of generating one")
+	noVerify := flag.Bool("no-verify", false, "Skip the pre-commit and commit-msg hooks when committing")
+	rewordLast := flag.Int("reword-last", 0, "Generate new messages for the last N commits, review them, and apply them with a rebase")
+	noLLM := flag.Bool("no-llm", false, "Build a basic message from the diffstat and changed files without calling the LLM")
+	amend := flag.Bool("amend", false, "Amend the previous commit, describing it together with the staged changes")
+	assumeYes := flag.Bool("yes", false, "Don't ask for confirmation before amending or committing many files, or what to do with the edited message")
+	allowProtected := flag.Bool("allow-protected", false, "Allow committing staged changes that touch protected_paths")
+	allowSecrets := flag.Bool("allow-secrets", false, "Send the diff to the LLM even if it appears to contain secrets")
+	candidates := flag.Int("candidates", 1, "Generate this many commit messages and choose one of them before editing")
+	noEdit := flag.Bool("no-edit", false, "Use the generated message without opening it in the editor")
+	scissors := flag.Bool("scissors", false, "Show the staged diff below a scissors line in the message file; git removes it when committing")
+	prStyle := flag.Bool("pr-style", false, "Generate the commit message from the staged diff using the PR template, for a single commit that makes up a whole PR")
+	review := flag.Bool("review", false, "Open the message together with the staged diff in a markdown file for review; only the message above the delimiter is committed")
+	modifiedOnly := flag.Bool("modified-only", false, "Describe and commit only modifications of existing files, leaving staged additions and deletions staged for a later commit")
+	allowEmpty := flag.Bool("allow-empty", false, "Allow committing with no staged changes, e.g. for marker commits that trigger a deploy")
+	commitMessage := flag.String("m", "", "Use this commit message instead of generating one (it is still opened in the editor)")
+	selectCommitsFlag := flag.Bool("select-commits", false, "Choose interactively which of the branch's commits the PR description is generated from")
+	allowEmptyPR := flag.Bool("allow-empty-pr", false, "Proceed with a placeholder PR body when the branch has no commits that differ from the target")
+	temperature := flag.Float64("temperature", 0, "Override the configured LLM temperature for this run (0-2)")
+	signingKey := flag.String("signing-key", "", "Sign the commit with this GPG/SSH key, overriding user.signingkey")
+	copyFlag := flag.Bool("copy", false, "Copy the final message to the clipboard (also with -dry-run)")
+	showCost := flag.Bool("show-cost", false, "Print the token usage and estimated cost after generating, whatever the log level")
+	quiet := flag.Bool("quiet", false, "Don't print the usage summary at exit")
+	apiKey := flag.String("api-key", "", "API key to use, taking precedence over the config file and environment")
+	maxConcurrent := flag.Int("max-concurrent-requests", 0, "Maximum number of LLM requests in flight at once, overriding max_concurrent_requests")
+	compareModelsFlag := flag.String("compare-models", "", "Comma-separated list of models to generate the message with, printing each with its token usage and cost (nothing is committed)")
+	reposFlag := flag.String("repos", "", "Comma-separated list of repository paths to generate PR descriptions for concurrently")
 	logLevelFlag := flag.String("log-level", "none", "Set logging level (debug, info, warn, error, none)")
 	flag.Parse()
 
@@ -37,71 +87,568 @@ func main() {
 		SetLogLevel(ERROR + 1)
 	}
 
+	// Reject conflicting flags before anything is generated
+	if *review && *generatePR {
+		fmt.Println("Error: -review only applies to commit messages")
+		exit(1)
+	}
+	if *review && *noEdit {
+		fmt.Println("Error: -review opens the editor, so it can't be combined with -no-edit")
+		exit(1)
+	}
+	if *scissors && (*generatePR || *review) {
+		fmt.Println("Error: -scissors only applies to commit messages and can't be combined with -review")
+		exit(1)
+	}
+
 	Log(INFO, "Starting application")
-	Log(DEBUG, "Command-line flags: pr=%v, target=%s, skip-create=%v, config=%s, dry-run=%v, log-level=%s",
-		*generatePR, *targetBranch, *skipCreate, *configPath, *dryRun, *logLevelFlag)
+	showUsageSummary = !*quiet
+	if showUsageSummary {
+		defer printUsageSummary()
+	}
+
+	if *repoDirFlag != "" {
+		repoDir = expandPath(*repoDirFlag)
+		if err := validateRepoDir(repoDir); err != nil {
+			Log(ERROR, "Invalid repository directory: %v", err)
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		Log(INFO, "Using repository directory: %s", repoDir)
+	}
+
+	if *signingKey != "" {
+		if err := validateSigningKey(*signingKey); err != nil {
+			Log(ERROR, "Invalid signing key: %v", err)
+			fmt.Println("Error:", err)
+			exit(exitConfigError)
+		}
+	}
+
+	// Subcommands that don't need the config
+	if flag.Arg(0) == "cache" {
+		if err := runCacheCommand(flag.Args()[1:]); err != nil {
+			Log(ERROR, "Cache command failed: %v", err)
+			fmt.Println("Error:", err)
+			exit(1)
+		}
+		return
+	}
+
+	Log(DEBUG, "Command-line flags: pr=%v, target=%s, skip-create=%v, fill=%v, config=%s, dry-run=%v, log-level=%s",
+		*generatePR, *targetBranch, *skipCreate, *useFill, configPaths.String(), *dryRun, *logLevelFlag)
 
 	// Load config from appropriate location
 	Log(INFO, "Loading configuration")
-	config, err := loadConfigFromPrioritizedLocations(*configPath)
+	config, configFile, err := ensureConfig(configPaths)
 	if err != nil {
 		Log(ERROR, "Failed to load config: %v", err)
-		fmt.Println("Error loading config:", err)
-		os.Exit(1)
+		var notFound *ConfigNotFoundError
+		var invalid *ConfigInvalidError
+		switch {
+		case errors.As(err, &notFound):
+			fmt.Println("No config found; create .gitscribe_config.json in the current directory or ~/.gitscribe/, or pass -config <file>")
+			fmt.Println("Searched:", strings.Join(notFound.Searched, ", "))
+		case errors.As(err, &invalid):
+			fmt.Printf("Config at %s is invalid: %v\n", invalid.Path, invalid.Err)
+		default:
+			fmt.Println("Error loading config:", err)
+		}
+		exit(exitConfigError)
+	}
+	Log(DEBUG, "Using config file: %s", configFile)
+
+	if *lintFile != "" {
+		lintMsg, err := readMessageToLint(*lintFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			exit(1)
+		}
+		problems := lintMessage(lintMsg, config, resolveCommitStyle(config.CommitStyle))
+		if len(problems) > 0 {
+			fmt.Fprintln(os.Stderr, "Commit message check failed:")
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+			}
+			exit(1)
+		}
+		Log(INFO, "Commit message passed all checks")
+		return
+	}
+
+	if err := setTempDir(config.TempDir); err != nil {
+		fmt.Println("Error:", err)
+		exit(exitConfigError)
+	}
+	editorOverride = config.Editor
+	firstLineEllipsis = config.FirstLineEllipsis
+
+	if *apiKey != "" {
+		registerSecret(*apiKey)
+		Log(DEBUG, "Using API key from -api-key flag: %s", maskSecret(*apiKey))
+		config.LLM.APIKey = *apiKey
+	}
+
+	// Subcommands that need the config
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "templates":
+			printTemplates(config)
+			return
+		case "models":
+			models, err := listModels(config.LLM)
+			if err != nil {
+				Log(ERROR, "Failed to list models: %v", err)
+				fmt.Println("Error listing models:", err)
+				exit(exitCode(err))
+			}
+			for _, model := range models {
+				fmt.Println(model)
+			}
+			return
+		default:
+			fmt.Printf("Unknown command: %s\n", flag.Arg(0))
+			exit(1)
+		}
+	}
+
+	// Fall back to git's own commit.template when GitScribe has no commit template configured
+	if config.CommitTemplate == "" {
+		if path := gitCommitTemplate(); path != "" {
+			Log(INFO, "Using git commit.template: %s", path)
+			config.CommitTemplate = path
+		}
+	}
+	// Likewise use the repository's own PR template, e.g. .github/pull_request_template.md.
+	// With -repos, each repository's template is looked up separately.
+	if (*generatePR || *prStyle) && *reposFlag == "" {
+		config.PRTemplate = resolvePRTemplate("", config.PRTemplate)
+	}
+
+	if flagWasSet("temperature") {
+		if *temperature < 0 || *temperature > 2 {
+			Log(ERROR, "Invalid temperature: %v", *temperature)
+			fmt.Println("Error: -temperature must be between 0 and 2")
+			exit(exitConfigError)
+		}
+		config.LLM.Temperature = *temperature
+	}
+	Log(DEBUG, "Effective LLM temperature: %.2f", config.LLM.Temperature)
+
+	if flagWasSet("max-concurrent-requests") {
+		if *maxConcurrent < 1 {
+			fmt.Println("Error: -max-concurrent-requests must be at least 1")
+			exit(exitConfigError)
+		}
+		config.MaxConcurrentRequests = *maxConcurrent
+	}
+	setMaxConcurrentRequests(config.MaxConcurrentRequests)
+
+	var compareModelList []string
+	if *compareModelsFlag != "" {
+		models, err := parseModelList(*compareModelsFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			exit(exitConfigError)
+		}
+		compareModelList = models
+	}
+	setModelPrices(config.ModelPrices)
+
+	config.LLM.EditPrompt = *editPrompt
+	config.LLM.ShowTokenBreakdown = *dryRun
+	config.LLM.RecordFile = expandPath(*recordFile)
+	config.LLM.ReplayFile = expandPath(*replayFile)
+
+	if *answersFile != "" {
+		answers, err := loadAnswersFile(expandPath(*answersFile))
+		if err != nil {
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		config.LLM.Answers = answers
+	}
+	config.LLM.Answers = append(config.LLM.Answers, answerFlags...)
+
+	if *reposFlag != "" {
+		Log(INFO, "Generating PR descriptions for multiple repositories")
+		repos := strings.Split(*reposFlag, ",")
+		target := *targetBranch
+		if target == "" {
+			target = config.DefaultTargetBranch
+		}
+		results := generatePRMessagesForRepos(repos, target, config)
+		printRepoResults(results)
+		for _, result := range results {
+			if result.Err != nil {
+				exit(exitCode(result.Err))
+			}
+		}
+		return
+	}
+
+	if err := ensureGitRepo(); err != nil {
+		Log(ERROR, "Not in a git repository")
+		fmt.Println("Error:", err)
+		exit(exitCode(err))
+	}
+
+	if *rewordLast > 0 {
+		if err := rewordLastCommits(*rewordLast, config, *noVerify); err != nil {
+			Log(ERROR, "Failed to reword commits: %v", err)
+			fmt.Println("Error rewording commits:", err)
+			exit(exitCode(err))
+		}
+		fmt.Println("Commits reworded successfully!")
+		return
 	}
 
 	var message string
+	// regenerate produces a fresh message for the given attempt; nil when the message can't be regenerated
+	var regenerate func(attempt int) (string, error)
+	// Labels the LLM proposed with -suggest-labels, added to the new PR
+	var suggestedLabels []string
+
+	if *issueNumber > 0 {
+		issue, err := fetchIssue(*issueNumber)
+		if err != nil {
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		config.LLM.ExtraContext = append(config.LLM.ExtraContext, issueContext(issue))
+		if *closeIssue {
+			config.ExtraTrailers = append(config.ExtraTrailers, closesTrailer(*issueNumber))
+		}
+	}
+
+	if *askIntentFlag || config.AskIntent {
+		if intent := askIntent(); intent != "" {
+			config.LLM.ExtraContext = append(config.LLM.ExtraContext, fmt.Sprintf("The goal of this change, in the author's words: %s", intent))
+		}
+	}
+
+	var existingPR PullRequest
+	if *updatePR {
+		if !*generatePR {
+			fmt.Println("Error: -update-pr only applies to PRs; pass it with -pr")
+			exit(1)
+		}
+		if config.Forge == ForgeGitLab {
+			fmt.Println("Error: -update-pr is only supported for GitHub")
+			exit(1)
+		}
+		var err error
+		existingPR, err = fetchPullRequest()
+		if err != nil {
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		if *targetBranch == "" {
+			*targetBranch = existingPR.BaseRefName
+		}
+		config.LLM.ExtraContext = append(config.LLM.ExtraContext, prFeedbackContext(existingPR))
+	}
 
 	if *generatePR {
 		Log(INFO, "Generating PR message")
+		// Show the description as it's written, unless it's going straight to a pipe or commit,
+		// or is printed afterwards anyway (-dry-run)
+		config.LLM.StreamOutput = !*noEdit && !*dryRun && stdoutIsTerminal()
+		if *suggestLabels && len(config.AllowedLabels) == 0 {
+			fmt.Println("Error: -suggest-labels needs the labels to choose from in allowed_labels in the config")
+			exit(exitConfigError)
+		}
+		*targetBranch = resolveTargetBranch("", *targetBranch, config.DefaultTargetBranch)
+		Log(INFO, "Target branch: %s", *targetBranch)
+		if *fetchTarget {
+			if err := fetchTargetBranch("", *targetBranch); err != nil {
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
+		}
+		// Check that a real run would succeed before spending an API call on the dry run
+		if *dryRun && !printPreflightChecks(prPreflightChecks(*targetBranch, config.Forge, *skipCreate)) {
+			Log(ERROR, "PR preflight checks failed")
+			fmt.Println("Error: a real run would fail; fix the checks above first.")
+			exit(1)
+		}
 		// Generate PR message
-		commits, err := getCommitMessages(*targetBranch)
+		// -no-llm lists the commits, for which the subjects are enough
+		commitList, err := getCommitMessages("", *targetBranch, config.PRCommitMessages == PRCommitMessagesFull && !*noLLM)
 		if err != nil {
 			Log(ERROR, "Failed to get commit messages: %v", err)
 			fmt.Println("Error:", err)
-			os.Exit(1)
+			exit(exitCode(err))
+		}
+
+		if len(commitList) > 0 && *selectCommitsFlag {
+			commitList, err = selectCommits(commitList)
+			if err != nil {
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
 		}
+		commits := joinCommitMessages(commitList)
 
-		message, err = createPRMessage(commits, config.PRTemplate, config.LLM, config.FirstLineLimit)
+		if commits == "" && *allowEmptyPR {
+			Log(INFO, "No commits differ from %s, using placeholder PR body", *targetBranch)
+			message, err = emptyPRMessage(*targetBranch)
+		} else if commits == "" {
+			Log(ERROR, "No commits found between the current branch and %s", *targetBranch)
+			fmt.Printf("Error: no commits found that aren't already in %s.\n", *targetBranch)
+			fmt.Println("Check the target branch with -target or default_target_branch in the config,")
+			fmt.Println("or use -allow-empty-pr to open a PR without changes (e.g. to trigger CI).")
+			exit(1)
+		} else if *compareModelsFlag != "" {
+			printModelComparisons(compareModels(compareModelList, config, func(c Config) (string, error) {
+				return createPRMessage(commits, c.PRTemplate, c.LLM, c.FirstLineLimit, c.MaxDiffTokens)
+			}))
+			return
+		} else if *noLLM {
+			message, err = createPRMessageWithoutLLM(commits, *targetBranch, config)
+		} else if *suggestLabels {
+			message, suggestedLabels, err = createStructuredPRMessage(commits, config)
+			regenerate = func(attempt int) (string, error) {
+				regenConfig := config
+				regenConfig.LLM.EnableCache = false
+				regenConfig.LLM.Temperature = regenerationTemperature(config.LLM, attempt)
+				regenerated, labels, err := createStructuredPRMessage(commits, regenConfig)
+				if err == nil {
+					suggestedLabels = labels
+					printSuggestedLabels(suggestedLabels)
+				}
+				return regenerated, err
+			}
+			if err == nil {
+				printSuggestedLabels(suggestedLabels)
+			}
+		} else {
+			message, err = createPRMessage(commits, config.PRTemplate, config.LLM, config.FirstLineLimit, config.MaxDiffTokens)
+			regenerate = func(attempt int) (string, error) {
+				llmConfig := config.LLM
+				llmConfig.EnableCache = false
+				llmConfig.Temperature = regenerationTemperature(config.LLM, attempt)
+				return createPRMessage(commits, config.PRTemplate, llmConfig, config.FirstLineLimit, config.MaxDiffTokens)
+			}
+		}
 		if err != nil {
 			Log(ERROR, "Failed to create PR message: %v", err)
 			fmt.Println("Error generating PR message:", err)
-			os.Exit(1)
+			exit(exitCode(err))
+		}
+
+		if *includeDiffStat {
+			diffStat, err := getDiffStat("", *targetBranch)
+			if err != nil {
+				Log(ERROR, "Failed to get diffstat: %v", err)
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
+			message = appendDiffStat(message, diffStat)
 		}
 	} else {
 		Log(INFO, "Generating commit message")
 		// Generate commit message (existing functionality)
+		if *modifiedOnly {
+			stagedDiffFilter = modifiedOnlyFilter
+		}
+		if *amend {
+			if !*assumeYes && !*dryRun {
+				confirmed, err := confirmAmend()
+				if err != nil {
+					fmt.Println("Error:", err)
+					exit(exitCode(err))
+				}
+				if !confirmed {
+					Log(INFO, "Amend aborted by user")
+					fmt.Println("Aborted.")
+					exit(1)
+				}
+			}
+			base, err := amendBase()
+			if err != nil {
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
+			stagedDiffBase = base
+			if *config.AmendPreserveIntent {
+				previous, err := previousCommitMessage()
+				if err != nil {
+					fmt.Println("Error:", err)
+					exit(exitCode(err))
+				}
+				config.LLM.ExtraContext = append(config.LLM.ExtraContext, amendContext(previous))
+			}
+		}
 		diff, err := getStagedDiff()
 		if err != nil {
 			Log(ERROR, "Failed to get staged diff: %v", err)
 			fmt.Println("Error:", err)
-			os.Exit(1)
+			exit(exitCode(err))
 		}
 
-		message, err = createCommitMessage(diff, config.CommitTemplate, config.LLM, config.FirstLineLimit)
+		emptyCommit := diff == "" && *allowEmpty
+		if !emptyCommit {
+			stagedFiles, err := getStagedFiles()
+			if err != nil {
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
+			if !*assumeYes && !confirmLargeChangeset(stagedFiles, config.ConfirmFileThreshold) {
+				Log(INFO, "Commit aborted by user")
+				fmt.Println("Aborted.")
+				exit(1)
+			}
+
+			protected, err := stagedProtectedFiles(config.ProtectedPaths)
+			if err != nil {
+				fmt.Println("Error:", err)
+				exit(exitCode(err))
+			}
+			if len(protected) > 0 {
+				if !*allowProtected {
+					Log(ERROR, "Staged changes touch %d protected files", len(protected))
+					fmt.Println("Error: the staged changes touch protected paths:")
+					for _, file := range protected {
+						fmt.Printf("  %s\n", file)
+					}
+					fmt.Println("Unstage them, or pass -allow-protected if this is intended.")
+					exit(1)
+				}
+				Log(WARN, "Committing changes to %d protected files (-allow-protected)", len(protected))
+			}
+
+			// Only the diff that would be sent to the LLM is scanned
+			if *commitMessage == "" && !*noLLM {
+				sentDiff, err := filterDiff(diff, commitDiffFilters(config))
+				if err != nil {
+					fmt.Println("Error:", err)
+					exit(exitCode(err))
+				}
+				if findings := scanDiffForSecrets(sentDiff); len(findings) > 0 {
+					if !*allowSecrets {
+						Log(ERROR, "Found %d likely secrets in the staged diff", len(findings))
+						fmt.Println("Error: the staged diff appears to contain secrets, which would be sent to the LLM API:")
+						for _, finding := range findings {
+							fmt.Printf("  %s:%d: %s\n", finding.File, finding.Line, finding.Kind)
+						}
+						fmt.Println("Remove them, add the files to exclude_paths, or pass -allow-secrets if they are safe to send.")
+						exit(1)
+					}
+					Log(WARN, "Sending a diff with %d likely secrets to the LLM (-allow-secrets)", len(findings))
+				}
+			}
+		}
+
+		generate := createCommitMessage
+		if *prStyle {
+			generate = createPRStyleCommitMessage
+		}
+
+		if *compareModelsFlag != "" {
+			printModelComparisons(compareModels(compareModelList, config, func(c Config) (string, error) {
+				return generate(diff, c)
+			}))
+			return
+		}
+
+		switch {
+		case *commitMessage != "":
+			Log(INFO, "Using the commit message given with -m")
+			message = *commitMessage
+		case emptyCommit && *noLLM:
+			err = fmt.Errorf("-no-llm can't describe an empty commit; pass the message with -m")
+		case emptyCommit:
+			message, err = createEmptyCommitMessage(config)
+		case *noLLM:
+			message, err = createCommitMessageWithoutLLM(config)
+		default:
+			if *candidates > 1 {
+				var generated []string
+				generated, err = generateCandidates(*candidates, config, func(c Config) (string, error) {
+					return generate(diff, c)
+				})
+				if err == nil {
+					message, err = chooseCandidate(generated)
+				}
+			} else {
+				message, err = generate(diff, config)
+			}
+			regenerate = func(attempt int) (string, error) {
+				regenConfig := config
+				regenConfig.LLM.EnableCache = false
+				regenConfig.LLM.Temperature = regenerationTemperature(config.LLM, attempt)
+				return generate(diff, regenConfig)
+			}
+		}
 		if err != nil {
 			Log(ERROR, "Failed to create commit message: %v", err)
 			fmt.Println("Error generating commit message:", err)
-			os.Exit(1)
+			exit(exitCode(err))
+		}
+	}
+
+	if report := sessionStats.costReport(); report != "" {
+		Log(INFO, "%s", report)
+		if *showCost {
+			fmt.Println(report)
+		}
+	}
+
+	finalizeMessage := func(message string) string {
+		// PR templates use markdown, where "#" starts a heading rather than a comment
+		if !*generatePR && !*prStyle {
+			message = stripTemplateComments(message, config.CommentPrefixes)
 		}
+		if *closeIssue && *issueNumber > 0 {
+			message = appendTrailer(message, closesTrailer(*issueNumber))
+		}
+		return postProcessMessage(message, config.PostProcessCommand)
 	}
+	message = finalizeMessage(message)
 
 	if *dryRun {
 		Log(INFO, "Dry run mode - displaying message and exiting")
 		fmt.Println("=== Generated Message (Dry Run) ===")
 		fmt.Println(message)
 		fmt.Println("==================================")
+		if *copyFlag {
+			copyMessage(message)
+		}
 		return
 	}
 
+	// In review mode the file shows the staged diff below the message
+	fileContent := func(message string) string { return message }
+	extension := "txt"
+	if *review {
+		reviewDiff, err := getStagedDiff()
+		if err != nil {
+			Log(ERROR, "Failed to get staged diff: %v", err)
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		fileContent = func(message string) string { return reviewDocument(message, reviewDiff) }
+		extension = "md"
+	} else if *scissors {
+		scissorsDiff, err := getStagedDiff()
+		if err != nil {
+			Log(ERROR, "Failed to get staged diff: %v", err)
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		char := commentChar()
+		fileContent = func(message string) string { return scissorsDocument(message, scissorsDiff, char) }
+	}
+
 	// Create a temporary message file
-	tempFile := filepath.Join(os.TempDir(), fmt.Sprintf("git_message_%d.txt", time.Now().Unix()))
+	tempFile := filepath.Join(tempDir(), fmt.Sprintf("git_message_%d.%s", time.Now().Unix(), extension))
 	Log(DEBUG, "Creating temporary message file: %s", tempFile)
 	file, err := os.Create(tempFile)
 	if err != nil {
 		Log(ERROR, "Failed to create temporary file: %v", err)
 		fmt.Println("Error creating temp file:", err)
-		os.Exit(1)
+		exit(1)
 	}
 	
 	// Only remove the temp file if we're not creating a PR or if it's a commit message
@@ -111,36 +658,130 @@ func main() {
 	}
 
 	Log(DEBUG, "Writing message to temporary file (%d bytes)", len(message))
-	if _, err := file.WriteString(message); err != nil {
+	if _, err := file.WriteString(toLineEndings(fileContent(message), config.LineEndings)); err != nil {
 		Log(ERROR, "Failed to write to temporary file: %v", err)
 		fmt.Println("Error writing to temp file:", err)
-		os.Exit(1)
+		exit(1)
 	}
 	if err := file.Close(); err != nil {
 		Log(ERROR, "Failed to close temporary file: %v", err)
 		fmt.Println("Error closing temp file:", err)
-		os.Exit(1)
+		exit(1)
 	}
 
-	// Open editor for the user to edit the message
-	Log(INFO, "Opening editor for user to edit message")
-	if err := openInVim(tempFile); err != nil {
-		Log(ERROR, "Failed to open editor: %v", err)
-		fmt.Println("Error opening editor:", err)
-		os.Exit(1)
+	// Open editor for the user to edit the message, then ask what to do with a commit message
+	askAfterEdit := !*generatePR && !*assumeYes && stdinIsTerminal()
+	regenerations := 0
+	for !*noEdit {
+		Log(INFO, "Opening editor for user to edit message")
+		if err := openInEditor(tempFile); err != nil {
+			Log(ERROR, "Failed to open editor: %v", err)
+			fmt.Println("Error opening editor:", err)
+			exit(exitCode(err))
+		}
+		if err := normalizeMessageFile(tempFile); err != nil {
+			Log(ERROR, "Failed to normalize message file: %v", err)
+			fmt.Println("Error reading edited message:", err)
+			exit(1)
+		}
+		if *review {
+			if err := extractReviewedMessage(tempFile); err != nil {
+				Log(ERROR, "Failed to read the reviewed message: %v", err)
+				fmt.Println("Error reading edited message:", err)
+				exit(1)
+			}
+		}
+
+		action := messageCommit
+		if config.ConfirmUnchanged && messageFileUnchanged(tempFile, message) {
+			action = askUnchangedAction(regenerate != nil)
+		} else if askAfterEdit {
+			action = askEditedAction(regenerate != nil)
+		}
+		if action == messageCommit {
+			break
+		}
+		if action == messageAbort {
+			Log(INFO, "Aborted by user after viewing the message")
+			fmt.Println("Aborted.")
+			os.Remove(tempFile)
+			exit(1)
+		}
+		if action == messageEdit {
+			if *review {
+				// Put the diff back below the edited message
+				edited, err := os.ReadFile(tempFile)
+				if err == nil {
+					err = os.WriteFile(tempFile, []byte(toLineEndings(fileContent(string(edited)), config.LineEndings)), 0644)
+				}
+				if err != nil {
+					Log(ERROR, "Failed to rewrite review file: %v", err)
+					fmt.Println("Error writing to temp file:", err)
+					exit(1)
+				}
+			}
+			continue
+		}
+
+		fmt.Println("Regenerating message...")
+		regenerations++
+		regenerated, err := regenerate(regenerations)
+		if err != nil {
+			Log(ERROR, "Failed to regenerate message: %v", err)
+			fmt.Println("Error regenerating message:", err)
+			exit(exitCode(err))
+		}
+		message = finalizeMessage(regenerated)
+		if err := os.WriteFile(tempFile, []byte(toLineEndings(fileContent(message), config.LineEndings)), 0644); err != nil {
+			Log(ERROR, "Failed to write to temporary file: %v", err)
+			fmt.Println("Error writing to temp file:", err)
+			exit(1)
+		}
 	}
 
-	if *generatePR {
+	if *copyFlag {
+		edited, err := os.ReadFile(tempFile)
+		if err != nil {
+			Log(ERROR, "Failed to read message file: %v", err)
+			fmt.Println("Error reading edited message:", err)
+			exit(1)
+		}
+		copyMessage(cutAtScissors(string(edited)))
+	}
+
+	if *generatePR && *updatePR && !*skipCreate {
+		Log(INFO, "Updating PR #%d", existingPR.Number)
+		if err := updatePullRequestBody(existingPR.Number, tempFile); err != nil {
+			fmt.Println("Error updating PR:", err)
+			exit(exitCode(err))
+		}
+		fmt.Println("PR updated successfully!")
+		fmt.Println("PR URL:", existingPR.URL)
+	} else if *generatePR {
 		if !*skipCreate {
-			// Create PR using GitHub CLI
-			Log(INFO, "Creating PR on GitHub")
-			fmt.Println("Creating PR on GitHub...")
-			prURL, err := createPullRequest(tempFile, *targetBranch)
+			// Create PR using the forge's CLI
+			Log(INFO, "Creating PR on %s", forgeDisplayName(config.Forge))
+			fmt.Printf("Creating PR on %s...\n", forgeDisplayName(config.Forge))
+			prURL, err := createPullRequest(tempFile, PROptions{
+				TargetBranch:           *targetBranch,
+				UseFill:                *useFill,
+				TitleFormat:            config.PRTitleFormat,
+				TitleFromBranch:        *titleFromBranch,
+				BranchTitlePattern:     config.BranchTitlePattern,
+				BranchTitleReplacement: config.BranchTitleReplacement,
+				TitleLimit:             config.FirstLineLimit,
+				Forge:                  config.Forge,
+				Draft:                  *draft,
+				Reviewers:              flagOrDefault(reviewers, config.PRReviewers),
+				Assignees:              flagOrDefault(assignees, config.PRAssignees),
+				Labels:                 mergeLabels(flagOrDefault(labels, config.PRLabels), suggestedLabels),
+			})
 			if err != nil {
 				Log(ERROR, "Failed to create PR: %v", err)
 				fmt.Println("Error creating PR:", err)
-				os.Exit(1)
+				exit(exitCode(err))
 			}
+			sessionStats.recordPullRequest()
 			Log(INFO, "PR created successfully: %s", prURL)
 			fmt.Println("PR created successfully!")
 			fmt.Println("PR URL:", prURL)
@@ -148,19 +789,79 @@ func main() {
 			// For PR messages without creation, just display the file path
 			Log(INFO, "Skipping PR creation, message saved to file")
 			fmt.Printf("PR message saved to: %s\n", tempFile)
-			fmt.Println("You can use this message when creating a PR on GitHub.")
+			fmt.Printf("You can use this message when creating a PR on %s.\n", forgeDisplayName(config.Forge))
 		}
 	} else {
 		// For commit messages, proceed with commit
 		Log(INFO, "Committing changes")
-		if err := commitChanges(tempFile); err != nil {
+		restage, err := unstageExcludedChanges()
+		if err != nil {
+			Log(ERROR, "Failed to prepare the index: %v", err)
+			fmt.Println("Error:", err)
+			exit(exitCode(err))
+		}
+		err = commitChanges(tempFile, CommitOptions{NoVerify: *noVerify, SigningKey: *signingKey, AllowEmpty: *allowEmpty, Amend: *amend, Scissors: *scissors})
+		if restageErr := restage(); restageErr != nil {
+			Log(ERROR, "Failed to restage excluded changes: %v", restageErr)
+			fmt.Println("Error:", restageErr)
+		}
+		if err != nil {
 			Log(ERROR, "Failed to commit changes: %v", err)
 			fmt.Println("Error committing changes:", err)
-			os.Exit(1)
+			exit(exitCode(err))
 		}
+		sessionStats.recordCommits(1)
 		Log(INFO, "Commit completed successfully")
 		fmt.Println("Commit successful!")
 	}
 	
 	Log(INFO, "Application completed successfully")
-} 
\ No newline at end of file
+}
+
+// Exit codes for failures that scripts may want to handle differently (documented in the
+// README). Any other failure exits with 1.
+const (
+	exitFailure         = 1
+	exitNoStagedChanges = 2
+	exitConfigError     = 3
+	exitAPIError        = 4
+	exitGitError        = 5
+)
+
+// exitCode maps an error to the exit code for its category
+func exitCode(err error) int {
+	var notFound *ConfigNotFoundError
+	var invalid *ConfigInvalidError
+	var apiErr *APIError
+	var gitErr *GitError
+	switch {
+	case errors.Is(err, ErrNoStagedChanges):
+		return exitNoStagedChanges
+	case errors.Is(err, ErrAPIKeyMissing), errors.Is(err, ErrEditorNotFound), errors.As(err, &notFound), errors.As(err, &invalid):
+		return exitConfigError
+	case errors.Is(err, ErrRateLimited), errors.As(err, &apiErr):
+		return exitAPIError
+	case errors.Is(err, ErrNotGitRepo), errors.As(err, &gitErr):
+		return exitGitError
+	}
+	return exitFailure
+}
+
+// flagOrDefault returns the values of a repeatable flag, or the configured defaults if it wasn't given
+func flagOrDefault(values stringListFlag, defaults []string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return defaults
+}
+
+// flagWasSet reports whether a flag was explicitly passed on the command line
+func flagWasSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
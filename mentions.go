@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// fileMentionsInstruction asks the LLM to reference every changed file in the message
+func fileMentionsInstruction(files []string) string {
+	return "Mention every changed file by name in the commit body: " + strings.Join(files, ", ")
+}
+
+// missingFileMentions returns the files that the message mentions neither by path nor by base name
+func missingFileMentions(message string, files []string) []string {
+	var missing []string
+	for _, file := range files {
+		if !strings.Contains(message, file) && !strings.Contains(message, filepath.Base(file)) {
+			missing = append(missing, file)
+		}
+	}
+	return missing
+}
+
+// enforceFileMentions asks the LLM once to add the changed files the message doesn't mention,
+// and warns if some are still missing afterwards
+func enforceFileMentions(message string, config Config, files []string) string {
+	missing := missingFileMentions(message, files)
+	if len(missing) == 0 {
+		return message
+	}
+
+	Log(INFO, "Generated message doesn't mention %d changed files, asking for a revision", len(missing))
+	revised, err := AddFileMentions(message, config.LLM, missing)
+	if err != nil {
+		Log(WARN, "Failed to add file mentions: %v", err)
+	} else {
+		message = trimFirstLine(revised, config.FirstLineLimit)
+		missing = missingFileMentions(message, files)
+		if len(missing) == 0 {
+			return message
+		}
+	}
+
+	Log(WARN, "Message still doesn't mention: %s", strings.Join(missing, ", "))
+	fmt.Printf("Warning: the generated message doesn't mention these changed files: %s\n", strings.Join(missing, ", "))
+	return message
+}
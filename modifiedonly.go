@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// stagedDiffFilter restricts which staged changes are described and committed (set by -modified-only).
+// It is passed to git as --diff-filter; when empty, all staged changes are used.
+var stagedDiffFilter string
+
+// modifiedOnlyFilter selects modifications of existing files, excluding additions and deletions
+const modifiedOnlyFilter = "M"
+
+// stagedDiffBase is the revision the staged changes are compared against instead of HEAD
+// (set by -amend to describe the previous commit together with the staged changes)
+var stagedDiffBase string
+
+// stagedDiffArgs returns the git arguments for diffing the staged changes, honoring
+// stagedDiffFilter and stagedDiffBase
+func stagedDiffArgs(extra ...string) []string {
+	args := []string{"diff", "--cached"}
+	if stagedDiffFilter != "" {
+		args = append(args, "--diff-filter="+stagedDiffFilter)
+	}
+	args = append(args, extra...)
+	if stagedDiffBase != "" {
+		args = append(args, stagedDiffBase)
+	}
+	return args
+}
+
+// unstageExcludedChanges temporarily removes the staged changes that stagedDiffFilter excludes
+// from the index, so only the selected changes are committed. The returned function stages
+// them again exactly as they were; it must be called whether or not the commit succeeds.
+func unstageExcludedChanges() (func() error, error) {
+	noop := func() error { return nil }
+	if stagedDiffFilter == "" {
+		return noop, nil
+	}
+
+	// A lowercase filter excludes the given change types; --no-renames splits renames into
+	// an addition and a deletion so both paths are handled
+	excludeFilter := "--diff-filter=" + strings.ToLower(stagedDiffFilter)
+	output, err := gitCommand("", "diff", "--cached", "--no-renames", "--name-only", "-z", excludeFilter).Output()
+	if err != nil {
+		return noop, fmt.Errorf("failed to list excluded staged changes: %v", err)
+	}
+	// Paths are NUL-terminated, as they may contain spaces
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return noop, nil
+	}
+
+	patch, err := gitCommand("", append([]string{"diff", "--cached", "--no-renames", "--binary", "--"}, paths...)...).Output()
+	if err != nil {
+		return noop, fmt.Errorf("failed to save excluded staged changes: %v", err)
+	}
+	Log(INFO, "Leaving %d staged files out of this commit: %s", len(paths), strings.Join(paths, ", "))
+	if err := gitCommand("", append([]string{"reset", "-q", "--"}, paths...)...).Run(); err != nil {
+		return noop, fmt.Errorf("failed to unstage excluded changes: %v", err)
+	}
+
+	return func() error {
+		Log(DEBUG, "Restaging %d excluded files", len(paths))
+		cmd := gitCommand("", "apply", "--cached", "--binary")
+		cmd.Stdin = bytes.NewReader(patch)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to restage %s: %v: %s", strings.Join(paths, ", "), err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}, nil
+}
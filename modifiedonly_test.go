@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnstageExcludedChangesPathsWithSpaces(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "existing file.txt", "one\n", "Add existing file")
+	writeFile(t, dir, "existing file.txt", "two\n")
+	writeFile(t, dir, "new file.txt", "new\n")
+	runGit(t, dir, "add", "-A")
+
+	stagedDiffFilter = modifiedOnlyFilter
+	t.Cleanup(func() { stagedDiffFilter = "" })
+
+	restage, err := unstageExcludedChanges()
+	if err != nil {
+		t.Fatalf("unstageExcludedChanges() error = %v", err)
+	}
+	if staged := runGit(t, dir, "diff", "--cached", "--name-only"); staged != "existing file.txt\n" {
+		t.Errorf("staged after unstaging = %q, want only the modified file", staged)
+	}
+	if err := restage(); err != nil {
+		t.Fatalf("restage() error = %v", err)
+	}
+	if staged := runGit(t, dir, "diff", "--cached", "--name-only"); !strings.Contains(staged, "new file.txt") {
+		t.Errorf("staged after restaging = %q, want the new file again", staged)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// RepoResult holds the outcome of generating a PR description for one repository
+type RepoResult struct {
+	Repo    string
+	Message string
+	Err     error
+}
+
+// generatePRMessagesForRepos generates a PR description for each repository, processing at most
+// config.MaxConcurrentRequests at a time. Results are returned in the same order as repos.
+func generatePRMessagesForRepos(repos []string, targetBranch string, config Config) []RepoResult {
+	Log(INFO, "Generating PR descriptions for %d repositories (max %d at a time)", len(repos), config.MaxConcurrentRequests)
+
+	// Questions read from stdin, which can't be shared between concurrent generations
+	llmConfig := config.LLM
+	llmConfig.disableQuestions()
+	llmConfig.EditPrompt = false
+
+	results := make([]RepoResult, len(repos))
+	sem := make(chan struct{}, config.MaxConcurrentRequests)
+	var wg sync.WaitGroup
+
+	for i, repo := range repos {
+		repo = expandPath(strings.TrimSpace(repo))
+		results[i].Repo = repo
+
+		wg.Add(1)
+		go func(i int, repo string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			Log(DEBUG, "Generating PR description for repository: %s", repo)
+			commitList, err := getCommitMessages(repo, resolveTargetBranch(repo, targetBranch, ""), config.PRCommitMessages == PRCommitMessagesFull)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			commits := joinCommitMessages(commitList)
+			templatePath := resolvePRTemplate(repo, config.PRTemplate)
+			results[i].Message, results[i].Err = createPRMessage(commits, templatePath, llmConfig, config.FirstLineLimit, config.MaxDiffTokens)
+		}(i, repo)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// printRepoResults prints a summary table of the generated PR titles or errors per repository,
+// followed by the full generated descriptions
+func printRepoResults(results []RepoResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tSTATUS\tTITLE / ERROR")
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(w, "%s\terror\t%s\n", result.Repo, strings.SplitN(result.Err.Error(), "\n", 2)[0])
+			continue
+		}
+		title, _ := splitTitleAndBody(result.Message)
+		fmt.Fprintf(w, "%s\tok\t%s\n", result.Repo, title)
+	}
+	w.Flush()
+
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		fmt.Printf("\n=== %s ===\n", result.Repo)
+		fmt.Println(result.Message)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// htmlCommentPattern matches <!-- --> comments, which templates use for guidance text
+var htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// blankLinesPattern matches runs of blank lines left behind after removing comments
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// templateSkeleton returns the template with its guidance comments removed
+func templateSkeleton(template string) string {
+	skeleton := htmlCommentPattern.ReplaceAllString(template, "")
+	var lines []string
+	for _, line := range strings.Split(skeleton, "\n") {
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// getStagedDiffStat returns the diffstat of the staged changes
+func getStagedDiffStat() (string, error) {
+	output, err := gitCommand("", stagedDiffArgs("--stat")...).Output()
+	if err != nil {
+		Log(ERROR, "Failed to get staged diffstat: %v", err)
+		return "", &GitError{Err: fmt.Errorf("failed to get staged diffstat: %v", err)}
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// mechanicalSubject describes a set of changed files without an LLM
+func mechanicalSubject(files []string) string {
+	switch {
+	case len(files) == 1:
+		return "Update " + files[0]
+	case commonDir(files) != "":
+		return fmt.Sprintf("Update %d files in %s", len(files), commonDir(files))
+	}
+	return fmt.Sprintf("Update %d files", len(files))
+}
+
+// fillTemplate builds a message from a subject, the template skeleton and mechanically derived sections
+func fillTemplate(subject string, template string, sections ...string) string {
+	parts := []string{subject}
+	if skeleton := templateSkeleton(template); skeleton != "" {
+		parts = append(parts, skeleton)
+	}
+	for _, section := range sections {
+		if strings.TrimSpace(section) != "" {
+			parts = append(parts, section)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// createCommitMessageWithoutLLM builds a basic commit message from the staged changes and
+// the template structure, for when the API is unavailable
+func createCommitMessageWithoutLLM(config Config) (string, error) {
+	Log(INFO, "Creating commit message without LLM")
+	files, err := getStagedFiles()
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		Log(ERROR, "No changes staged for commit")
+		return "", fmt.Errorf("%w. Please stage changes before committing.", ErrNoStagedChanges)
+	}
+	diffStat, err := getStagedDiffStat()
+	if err != nil {
+		return "", err
+	}
+	template, err := readTemplate(config.CommitTemplate)
+	if err != nil {
+		Log(WARN, "Could not read commit template, using a plain message: %v", err)
+	}
+
+	branch := "HEAD"
+	if output, err := gitCommand("", "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+		branch = strings.TrimSpace(string(output))
+	}
+
+	var message string
+	if usesTemplateVariables(string(template)) {
+		message = fillTemplate(mechanicalSubject(files), expandTemplateVariables(string(template), files, true), "Branch: "+branch)
+	} else {
+		message = fillTemplate(mechanicalSubject(files), string(template),
+			"Changed files:\n- "+strings.Join(files, "\n- "),
+			diffStat,
+			"Branch: "+branch)
+	}
+	return trimFirstLine(message, config.FirstLineLimit), nil
+}
+
+// createPRMessageWithoutLLM builds a basic PR message from the branch's commits, the diffstat
+// against the target branch and the template structure, for when the API is unavailable
+func createPRMessageWithoutLLM(commits string, targetBranch string, config Config) (string, error) {
+	Log(INFO, "Creating PR message without LLM")
+	if commits == "" {
+		Log(ERROR, "No commits found between branches")
+		return "", fmt.Errorf("no commits found between branches. Please make some commits first.")
+	}
+	branch, err := getCurrentBranch("")
+	if err != nil {
+		return "", err
+	}
+	diffStat, err := getDiffStat("", targetBranch)
+	if err != nil {
+		return "", err
+	}
+	template, err := readTemplate(config.PRTemplate)
+	if err != nil {
+		Log(WARN, "Could not read PR template, using a plain message: %v", err)
+	}
+
+	commitList := strings.Split(commits, "\n")
+	subject := commitList[0]
+	if len(commitList) > 1 {
+		subject = fmt.Sprintf("Changes from %s", branch)
+	}
+	message := fillTemplate(subject, string(template),
+		"Commits:\n- "+strings.Join(commitList, "\n- "),
+		"```\n"+diffStat+"\n```")
+	return trimFirstLine(message, config.FirstLineLimit), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// defaultOllamaBaseURL is the local Ollama server used when base_url is not configured
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// OllamaRequest is the request body of the Ollama chat API
+type OllamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+		NumPredict  int     `json:"num_predict,omitempty"`
+	} `json:"options"`
+}
+
+// OllamaResponse is the response body of the Ollama chat API. Unlike OpenAI, the reply is
+// nested under a single message object rather than a choices array.
+type OllamaResponse struct {
+	Message         *ChatMessage `json:"message,omitempty"`
+	DoneReason      string       `json:"done_reason"`
+	PromptEvalCount int          `json:"prompt_eval_count"`
+	EvalCount       int          `json:"eval_count"`
+	Error           string       `json:"error,omitempty"`
+}
+
+// ollamaProvider talks to a local Ollama server, which needs no API key
+type ollamaProvider struct{}
+
+// Chat sends the conversation to the Ollama chat API and returns the reply
+func (ollamaProvider) Chat(messages []ChatMessage, config LLMConfig) (string, error) {
+	request := OllamaRequest{Model: config.Model, Messages: messages}
+	request.Options.Temperature = config.Temperature
+	request.Options.NumPredict = config.MaxTokens
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL(config, "/api/chat"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeaders(req, config)
+
+	release := acquireRequestSlot()
+	defer release()
+
+	_, body, err := doHTTPRequest(req, config)
+	if err != nil {
+		return "", fmt.Errorf("%w (is Ollama running at %s?)", err, apiURL(config, ""))
+	}
+
+	var response OllamaResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if response.Error != "" {
+		return "", &APIError{Err: fmt.Errorf("API error: %s", response.Error)}
+	}
+	if response.Message == nil || response.Message.Content == "" {
+		return "", fmt.Errorf("no response from API")
+	}
+
+	if err := checkFinishReason(response.DoneReason, config); err != nil {
+		return "", err
+	}
+
+	usage := Usage{
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+		TotalTokens:      response.PromptEvalCount + response.EvalCount,
+	}
+	Log(DEBUG, "Token usage: %d prompt, %d completion", usage.PromptTokens, usage.CompletionTokens)
+	recordUsage(config, usage)
+
+	return response.Message.Content, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaChat(t *testing.T) {
+	var request OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("request path = %s, want /api/chat", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Authorization header = %q, want none without an API key", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Add retries"}, "done_reason": "stop", "prompt_eval_count": 12, "eval_count": 3}`))
+	}))
+	defer server.Close()
+
+	var usage Usage
+	config := LLMConfig{Provider: ProviderOllama, BaseURL: server.URL, Model: "llama3", Temperature: 0.2, MaxTokens: 100, UsageTotal: &usage}
+	if err := requireAPIKey(config); err != nil {
+		t.Errorf("requireAPIKey() error: %v, want none for Ollama", err)
+	}
+	got, err := makeLLMRequest([]ChatMessage{{Role: "user", Content: "diff"}}, config)
+	if err != nil {
+		t.Fatalf("makeLLMRequest() error: %v", err)
+	}
+	if got != "Add retries" {
+		t.Errorf("makeLLMRequest() = %q, want %q", got, "Add retries")
+	}
+	if request.Model != "llama3" || request.Stream || request.Options.Temperature != 0.2 || request.Options.NumPredict != 100 {
+		t.Errorf("request = %+v, want the model, options and no streaming", request)
+	}
+	if want := (Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
+func TestOllamaChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "model \"llama9\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	config := LLMConfig{Provider: ProviderOllama, BaseURL: server.URL, Model: "llama9"}
+	_, err := makeLLMRequest([]ChatMessage{{Role: "user", Content: "diff"}}, config)
+	if err == nil || !strings.Contains(err.Error(), "not found, try pulling it first") {
+		t.Errorf("makeLLMRequest() error = %v, want Ollama's error", err)
+	}
+	if exitCode(err) != exitAPIError {
+		t.Errorf("exitCode(%v) = %d, want %d", err, exitCode(err), exitAPIError)
+	}
+}
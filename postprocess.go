@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// postProcessMessage pipes the message through the configured shell command (stdin to stdout).
+// If the command fails or prints nothing, the unprocessed message is returned.
+func postProcessMessage(message string, command string) string {
+	if strings.TrimSpace(command) == "" {
+		return message
+	}
+
+	Log(INFO, "Post-processing message with: %s", command)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = repoDir
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		Log(WARN, "Post-process command failed, using the unprocessed message: %v", err)
+		return message
+	}
+	processed := strings.TrimRight(stdout.String(), "\n")
+	if strings.TrimSpace(processed) == "" {
+		Log(WARN, "Post-process command produced no output, using the unprocessed message")
+		return message
+	}
+	Log(DEBUG, "Post-processed message (%d -> %d chars)", len(message), len(processed))
+	return processed
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// preflightCheck is the outcome of one read-only check that a PR could be created
+type preflightCheck struct {
+	Name string
+	Err  error // nil if the check passed
+}
+
+// prPreflightChecks verifies, without changing anything, the preconditions for creating a PR:
+// a branch to push, an origin remote, an existing target branch and, unless the PR isn't
+// going to be created, an installed and authenticated forge CLI
+func prPreflightChecks(targetBranch string, forge string, skipCreate bool) []preflightCheck {
+	var checks []preflightCheck
+	add := func(name string, err error) {
+		checks = append(checks, preflightCheck{Name: name, Err: err})
+	}
+
+	branch, err := getCurrentBranch("")
+	if err == nil && branch == targetBranch {
+		err = fmt.Errorf("the current branch is the target branch %s", targetBranch)
+	}
+	add("current branch can be pushed", err)
+
+	if output, err := gitCommand("", "remote", "get-url", "origin").CombinedOutput(); err != nil {
+		add("origin remote is configured", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output))))
+	} else {
+		add("origin remote is configured", nil)
+	}
+
+	add(fmt.Sprintf("target branch %s exists", targetBranch), targetBranchExists(targetBranch))
+
+	if skipCreate {
+		return checks
+	}
+	cli := forgeCLIFor(forge)
+	if _, err := exec.LookPath(cli.Command); err != nil {
+		add(fmt.Sprintf("%s is installed", cli.Name), fmt.Errorf("not found; install it from %s", cli.InstallURL))
+		return checks
+	}
+	add(fmt.Sprintf("%s is installed", cli.Name), nil)
+
+	cmd := exec.Command(cli.Command, "auth", "status")
+	cmd.Dir = repoDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		add(fmt.Sprintf("%s is authenticated", cli.Command), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output))))
+	} else {
+		add(fmt.Sprintf("%s is authenticated", cli.Command), nil)
+	}
+	return checks
+}
+
+// targetBranchExists checks that the target branch exists locally or as a remote-tracking branch
+func targetBranchExists(targetBranch string) error {
+	if branchExists("", targetBranch) {
+		return nil
+	}
+	return fmt.Errorf("neither %s nor origin/%s exists (fetch it, or pick another with -target)", targetBranch, targetBranch)
+}
+
+// printPreflightChecks prints the outcome of each check and reports whether all of them passed
+func printPreflightChecks(checks []preflightCheck) bool {
+	passed := true
+	fmt.Println("=== PR Preflight Checks ===")
+	for _, check := range checks {
+		if check.Err != nil {
+			passed = false
+			fmt.Printf("  [FAIL] %s: %v\n", check.Name, check.Err)
+		} else {
+			fmt.Printf("  [ok]   %s\n", check.Name)
+		}
+	}
+	fmt.Println("===========================")
+	return passed
+}
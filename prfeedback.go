@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PRComment is a comment or review on a pull request, as reported by gh pr view
+type PRComment struct {
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Body  string `json:"body"`
+	State string `json:"state"` // Reviews only, e.g. APPROVED or CHANGES_REQUESTED
+}
+
+// PullRequest holds the parts of the branch's open PR used to update its description
+type PullRequest struct {
+	Number      int         `json:"number"`
+	URL         string      `json:"url"`
+	Body        string      `json:"body"`
+	BaseRefName string      `json:"baseRefName"`
+	Comments    []PRComment `json:"comments"`
+	Reviews     []PRComment `json:"reviews"`
+}
+
+// fetchPullRequest retrieves the open PR of the current branch, with its comments and reviews,
+// using the gh CLI
+func fetchPullRequest() (PullRequest, error) {
+	Log(INFO, "Fetching the pull request of the current branch")
+	if _, err := exec.LookPath("gh"); err != nil {
+		Log(ERROR, "GitHub CLI (gh) not found")
+		return PullRequest{}, fmt.Errorf("GitHub CLI (gh) not found. Please install it from https://cli.github.com/")
+	}
+
+	cmd := exec.Command("gh", "pr", "view", "--json", "number,url,body,baseRefName,comments,reviews")
+	cmd.Dir = repoDir
+	output, err := cmd.Output()
+	if err != nil {
+		Log(ERROR, "Failed to fetch the pull request: %v", err)
+		return PullRequest{}, fmt.Errorf("failed to fetch the pull request of the current branch (is one open?): %v", err)
+	}
+
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		Log(ERROR, "Failed to parse the pull request: %v", err)
+		return PullRequest{}, fmt.Errorf("failed to parse the pull request: %v", err)
+	}
+	Log(DEBUG, "Fetched PR #%d with %d comments and %d reviews", pr.Number, len(pr.Comments), len(pr.Reviews))
+	return pr, nil
+}
+
+// prFeedbackContext formats the current description and the review feedback of a PR as
+// additional prompt context
+func prFeedbackContext(pr PullRequest) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("This updates the description of the existing pull request #%d. ", pr.Number))
+	sb.WriteString("Reflect the decisions and changes that came out of the review feedback below where they apply, ")
+	sb.WriteString("but describe the changes themselves rather than the review.\n\n")
+	sb.WriteString("Current description:\n")
+	sb.WriteString(strings.TrimSpace(pr.Body))
+	sb.WriteString("\n\nReview feedback:\n")
+
+	feedback := 0
+	for _, review := range pr.Reviews {
+		body := strings.TrimSpace(review.Body)
+		if body == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- Review by %s (%s): %s\n", review.Author.Login, strings.ToLower(strings.ReplaceAll(review.State, "_", " ")), body))
+		feedback++
+	}
+	for _, comment := range pr.Comments {
+		body := strings.TrimSpace(comment.Body)
+		if body == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- Comment by %s: %s\n", comment.Author.Login, body))
+		feedback++
+	}
+	if feedback == 0 {
+		sb.WriteString("(none)\n")
+	}
+	return sb.String()
+}
+
+// updatePullRequestBody pushes the current branch, so the PR shows the commits the description
+// was generated from, and replaces the description of the PR with the body of the message
+// file (everything after the first line, which is the title)
+func updatePullRequestBody(number int, prMessageFile string) error {
+	branch, err := getCurrentBranch("")
+	if err != nil {
+		return err
+	}
+	if err := pushBranch(branch); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(prMessageFile)
+	if err != nil {
+		Log(ERROR, "Failed to read PR message file: %v", err)
+		return fmt.Errorf("failed to read PR message file: %v", err)
+	}
+	_, body := splitTitleAndBody(string(data))
+
+	bodyFile := prMessageFile + ".body"
+	if err := ioutil.WriteFile(bodyFile, []byte(body), 0644); err != nil {
+		Log(ERROR, "Failed to write PR body file: %v", err)
+		return fmt.Errorf("failed to write PR body file: %v", err)
+	}
+	defer os.Remove(bodyFile)
+
+	cmd := exec.Command("gh", "pr", "edit", strconv.Itoa(number), "--body-file", bodyFile)
+	cmd.Dir = repoDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		Log(ERROR, "Failed to update PR #%d: %v\n%s", number, err, string(output))
+		return fmt.Errorf("failed to update PR #%d: %v\n%s", number, err, string(output))
+	}
+	Log(INFO, "Updated the description of PR #%d", number)
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StructuredPR is a PR title, body and labels proposed together by the LLM (-suggest-labels)
+type StructuredPR struct {
+	Title  string   `json:"title"`
+	Body   string   `json:"body"`
+	Labels []string `json:"labels"`
+}
+
+// structuredPRInstruction asks the LLM to answer with a StructuredPR, choosing labels from allowed
+func structuredPRInstruction(allowed []string) string {
+	quoted := make([]string, len(allowed))
+	for i, label := range allowed {
+		quoted[i] = fmt.Sprintf("%q", label)
+	}
+	return fmt.Sprintf(`Respond with only a JSON object of the form {"title": "...", "body": "...", "labels": ["..."]}, without code fences.
+	The title is a short summary of the PR on one line. The body is the PR description following the template.
+	The labels are those that apply to the PR, chosen only from: %s. Use an empty list if none apply.`, strings.Join(quoted, ", "))
+}
+
+// jsonObjectPattern matches the outermost JSON object in a response, e.g. inside a code fence
+var jsonObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// parseStructuredPR extracts the title, body and labels from the LLM's response
+func parseStructuredPR(response string) (StructuredPR, error) {
+	var pr StructuredPR
+	match := jsonObjectPattern.FindString(response)
+	if match == "" {
+		return pr, fmt.Errorf("no JSON object in the response")
+	}
+	if err := json.Unmarshal([]byte(match), &pr); err != nil {
+		return pr, fmt.Errorf("invalid JSON in the response: %v", err)
+	}
+	pr.Title = strings.TrimSpace(pr.Title)
+	pr.Body = strings.TrimSpace(pr.Body)
+	if pr.Title == "" {
+		return pr, fmt.Errorf("the response has no title")
+	}
+	return pr, nil
+}
+
+// validateLabels keeps the labels that are in allowed (ignoring case, and using the allowed
+// spelling), dropping duplicates. It also returns the labels that aren't allowed.
+func validateLabels(labels []string, allowed []string) ([]string, []string) {
+	var valid, rejected []string
+	seen := map[string]bool{}
+	for _, label := range labels {
+		match := ""
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSpace(label), a) {
+				match = a
+				break
+			}
+		}
+		if match == "" {
+			rejected = append(rejected, label)
+			continue
+		}
+		if !seen[match] {
+			seen[match] = true
+			valid = append(valid, match)
+		}
+	}
+	return valid, rejected
+}
+
+// createStructuredPRMessage generates the PR title and body together with labels chosen from
+// allowed_labels. A response that isn't the expected JSON is used as the message as-is, without labels.
+func createStructuredPRMessage(commits string, config Config) (string, []string, error) {
+	llmConfig := config.LLM
+	llmConfig.ExtraInstructions = append(append([]string(nil), llmConfig.ExtraInstructions...), structuredPRInstruction(config.AllowedLabels))
+	// The raw JSON isn't worth showing as it arrives
+	llmConfig.StreamOutput = false
+
+	// The first line limit applies to the title, once it's out of the JSON
+	response, err := createPRMessage(commits, config.PRTemplate, llmConfig, 0, config.MaxDiffTokens)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pr, err := parseStructuredPR(response)
+	if err != nil {
+		Log(WARN, "Could not read the title and labels from the response, using it as the message: %v", err)
+		return trimFirstLine(response, config.FirstLineLimit), nil, nil
+	}
+	labels, rejected := validateLabels(pr.Labels, config.AllowedLabels)
+	if len(rejected) > 0 {
+		Log(WARN, "Ignoring suggested labels not in allowed_labels: %s", strings.Join(rejected, ", "))
+	}
+
+	message := pr.Title
+	if pr.Body != "" {
+		message += "\n\n" + pr.Body
+	}
+	return trimFirstLine(message, config.FirstLineLimit), labels, nil
+}
+
+// printSuggestedLabels shows the labels the LLM proposed, which aren't part of the edited message
+func printSuggestedLabels(labels []string) {
+	if len(labels) == 0 {
+		fmt.Println("No labels suggested.")
+		return
+	}
+	fmt.Printf("Suggested labels: %s\n", strings.Join(labels, ", "))
+}
+
+// mergeLabels appends the labels in extra that aren't already in labels
+func mergeLabels(labels []string, extra []string) []string {
+	merged := append([]string(nil), labels...)
+	for _, label := range extra {
+		found := false
+		for _, existing := range merged {
+			if existing == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, label)
+		}
+	}
+	return merged
+}
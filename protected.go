@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stagedProtectedFiles returns the staged files that fall under one of the protected paths.
+// Renames are listed as a deletion and an addition, so moving a file out of a protected
+// path counts as touching it.
+func stagedProtectedFiles(protectedPaths []string) ([]string, error) {
+	if len(protectedPaths) == 0 {
+		return nil, nil
+	}
+	output, err := gitCommand("", stagedDiffArgs("--name-only", "--no-renames")...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staged files: %v", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		for _, protected := range protectedPaths {
+			if pathMatches(file, protected) {
+				Log(DEBUG, "Staged file %s matches protected path %s", file, protected)
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Supported LLM providers
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// Provider sends a conversation to an LLM API and returns the reply.
+// Implementations translate ChatMessage to the API's request format and surface
+// its error bodies as errors.
+type Provider interface {
+	Chat(messages []ChatMessage, config LLMConfig) (string, error)
+}
+
+// providerFor returns the provider selected by the config (OpenAI when unset)
+func providerFor(config LLMConfig) (Provider, error) {
+	switch config.Provider {
+	case "", ProviderOpenAI:
+		return openAIProvider{}, nil
+	case ProviderAnthropic:
+		return anthropicProvider{}, nil
+	case ProviderOllama:
+		return ollamaProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q (expected openai, anthropic or ollama)", config.Provider)
+}
+
+// defaultModel returns the model used for a provider when none is configured
+func defaultModel(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "claude-3-5-sonnet-latest"
+	case ProviderOllama:
+		return "llama3.1"
+	}
+	return "gpt-4"
+}
+
+// apiKeyEnvVar returns the environment variable the provider's API key is read from,
+// or "" for local providers that don't need one
+func apiKeyEnvVar(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "ANTHROPIC_API_KEY"
+	case ProviderOllama:
+		return ""
+	}
+	return "OPENAI_KEY"
+}
+
+// requireAPIKey returns an error if the provider needs an API key and none is configured
+func requireAPIKey(config LLMConfig) error {
+	if config.APIKey != "" || config.ReplayFile != "" || apiKeyEnvVar(config.Provider) == "" {
+		return nil
+	}
+	name := "OpenAI"
+	if config.Provider == ProviderAnthropic {
+		name = "Anthropic"
+	}
+	return fmt.Errorf("%s %w. Set the %s environment variable", name, ErrAPIKeyMissing, apiKeyEnvVar(config.Provider))
+}
+
+// setAuthHeaders adds the provider's authentication headers to a request
+func setAuthHeaders(req *http.Request, config LLMConfig) {
+	if config.Provider == ProviderAnthropic {
+		req.Header.Set("x-api-key", config.APIKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+		return
+	}
+	if config.APIKey == "" {
+		return
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.APIKey))
+}
+
+// openAIProvider talks to the OpenAI chat completions API and compatible servers
+type openAIProvider struct{}
+
+// Chat sends the conversation, retrying once with the alternative token limit parameter
+// if the model rejects the one chosen for it
+func (openAIProvider) Chat(messages []ChatMessage, config LLMConfig) (string, error) {
+	useCompletionTokens := usesMaxCompletionTokens(config.Model)
+	Log(DEBUG, "Using max_completion_tokens for model %s: %v", config.Model, useCompletionTokens)
+
+	send := sendChatRequest
+	if config.StreamOutput {
+		send = sendStreamingChatRequest
+	}
+	response, err := send(newChatRequest(messages, config, useCompletionTokens), config)
+	if err != nil && isTokenParamError(err) {
+		Log(WARN, "Model %s rejected the token limit parameter, retrying with the alternative name", config.Model)
+		response, err = send(newChatRequest(messages, config, !useCompletionTokens), config)
+	}
+	return response, err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// branchUpToDate reports whether the branch has an upstream that already has all of its commits,
+// in which case there is nothing to push. The upstream is fetched first, as the local
+// remote-tracking branch may be stale, e.g. if the remote branch was deleted after a merge.
+func branchUpToDate(branch string) bool {
+	upstream, err := gitCommand("", "rev-parse", "--abbrev-ref", "--symbolic-full-name", branch+"@{upstream}").Output()
+	if err != nil {
+		Log(DEBUG, "Branch %s has no upstream yet", branch)
+		return false
+	}
+	remote, err := gitCommand("", "config", "branch."+branch+".remote").Output()
+	if err != nil {
+		return false
+	}
+	merge, err := gitCommand("", "config", "branch."+branch+".merge").Output()
+	if err != nil {
+		return false
+	}
+	if err := fetchRemoteBranch(strings.TrimSpace(string(remote)), strings.TrimPrefix(strings.TrimSpace(string(merge)), "refs/heads/")); err != nil {
+		Log(DEBUG, "Could not fetch the upstream of %s, pushing: %v", branch, err)
+		return false
+	}
+	output, err := gitCommand("", "rev-list", "--count", strings.TrimSpace(string(upstream))+".."+branch).Output()
+	if err != nil {
+		return false
+	}
+	ahead, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil || ahead > 0 {
+		return false
+	}
+	Log(INFO, "Branch %s is already up to date with %s", branch, strings.TrimSpace(string(upstream)))
+	return true
+}
+
+// isNonFastForward reports whether git push was rejected because the remote branch has
+// commits that the local branch doesn't
+func isNonFastForward(stderr string) bool {
+	return strings.Contains(stderr, "non-fast-forward") || (strings.Contains(stderr, "[rejected]") && strings.Contains(stderr, "fetch first"))
+}
+
+// pushBranch pushes the branch to origin and sets it as the upstream, skipping the push if the
+// upstream already has every commit. If the remote rejects a non-fast-forward push, it explains
+// why and offers to retry with --force-with-lease.
+func pushBranch(branch string) error {
+	if branchUpToDate(branch) {
+		fmt.Println("Branch is already pushed; skipping push.")
+		return nil
+	}
+
+	stderr, err := runPush(branch)
+	if err == nil {
+		return nil
+	}
+	Log(ERROR, "Failed to push to remote: %v", err)
+	if !isNonFastForward(stderr) {
+		return &GitError{Err: fmt.Errorf("failed to push to remote (see git's output above): %v", err)}
+	}
+
+	fmt.Printf("The remote branch %s has commits that your local branch doesn't (non-fast-forward).\n", branch)
+	fmt.Println("Pull them first (git pull --rebase) to keep them, or force-push to replace them.")
+	// --force-with-lease compares against origin/<branch>, which must be current to succeed
+	if err := fetchRemoteBranch("origin", branch); err != nil {
+		Log(ERROR, "Failed to fetch %s: %v", branch, err)
+		return &GitError{Err: fmt.Errorf("push rejected as non-fast-forward, and fetching the remote branch failed: %v", err)}
+	}
+	if !stdinIsTerminal() || !confirm("Force-push with --force-with-lease?") {
+		return &GitError{Err: fmt.Errorf("push rejected as non-fast-forward; pull first or force-push")}
+	}
+	if _, err := runPush(branch, "--force-with-lease"); err != nil {
+		Log(ERROR, "Force-push failed: %v", err)
+		return &GitError{Err: fmt.Errorf("failed to force-push to remote (see git's output above): %v", err)}
+	}
+	return nil
+}
+
+// fetchRemoteBranch updates the remote-tracking branch of branch on remote. It fails if the
+// remote no longer has the branch.
+func fetchRemoteBranch(remote string, branch string) error {
+	output, err := gitCommand("", "fetch", "-q", remote, branch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git fetch %s %s: %v: %s", remote, branch, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runPush runs git push for the branch, showing git's output and returning its stderr
+func runPush(branch string, extraArgs ...string) (string, error) {
+	args := append([]string{"push", "-u"}, extraArgs...)
+	args = append(args, "origin", branch)
+	Log(INFO, "Running git %s", strings.Join(args, " "))
+	cmd := gitCommand("", args...)
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	return stderr.String(), err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepoWithRemote creates a test repository with a bare origin and the branch feature
+// pushed to it
+func newTestRepoWithRemote(t *testing.T) (string, string) {
+	t.Helper()
+	dir := newTestRepo(t)
+	remote := filepath.Join(t.TempDir(), "origin.git")
+	runGit(t, dir, "init", "-q", "--bare", remote)
+	runGit(t, dir, "remote", "add", "origin", remote)
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "feature.txt", "feature\n", "Add feature")
+	runGit(t, dir, "push", "-q", "-u", "origin", "feature")
+	return dir, remote
+}
+
+func TestBranchUpToDate(t *testing.T) {
+	dir, remote := newTestRepoWithRemote(t)
+	if !branchUpToDate("feature") {
+		t.Error("branchUpToDate() = false right after pushing")
+	}
+	commitFile(t, dir, "more.txt", "more\n", "Add more")
+	if branchUpToDate("feature") {
+		t.Error("branchUpToDate() = true with an unpushed commit")
+	}
+	runGit(t, dir, "push", "-q", "origin", "feature")
+
+	// The remote branch is deleted, e.g. after the PR was merged, but origin/feature remains
+	runGit(t, remote, "branch", "-D", "feature")
+	if branchUpToDate("feature") {
+		t.Error("branchUpToDate() = true after the remote branch was deleted")
+	}
+}
+
+func TestPushBranchRejectedFetchesRemoteBranch(t *testing.T) {
+	if stdinIsTerminal() {
+		t.Skip("pushBranch would ask whether to force-push")
+	}
+	dir, remote := newTestRepoWithRemote(t)
+
+	// Someone else pushes to the branch
+	other := filepath.Join(t.TempDir(), "other")
+	runGit(t, dir, "clone", "-q", "-b", "feature", remote, other)
+	commitFile(t, other, "theirs.txt", "theirs\n", "Add theirs")
+	runGit(t, other, "push", "-q", "origin", "feature")
+	theirs := strings.TrimSpace(runGit(t, other, "rev-parse", "HEAD"))
+
+	commitFile(t, dir, "ours.txt", "ours\n", "Add ours")
+	// Without a terminal, the force-push isn't offered
+	if err := pushBranch("feature"); err == nil {
+		t.Fatal("pushBranch() succeeded despite the non-fast-forward")
+	}
+	if tracking := strings.TrimSpace(runGit(t, dir, "rev-parse", "origin/feature")); tracking != theirs {
+		t.Errorf("origin/feature = %s, want the remote's %s so a lease would hold", tracking, theirs)
+	}
+}
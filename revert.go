@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// revertSearchDepth is the number of recent commits checked when looking for the commit a diff reverts
+const revertSearchDepth = 10
+
+// Placeholders in revert_prompt, replaced with the reverted commit's subject and SHA
+const (
+	revertSubjectVariable = "{{subject}}"
+	revertSHAVariable     = "{{sha}}"
+)
+
+// defaultRevertPrompt is the instruction used for revert commits when revert_prompt is not configured
+const defaultRevertPrompt = `These staged changes revert an earlier commit. Do not describe them as new work.
+	The first line of the commit message MUST be: Revert "{{subject}}"
+	After a blank line, include the line: This reverts commit {{sha}}.
+	Then briefly explain why the change is being reverted, if the diff makes that clear.`
+
+// RevertInfo identifies the commit being reverted
+type RevertInfo struct {
+	SHA     string
+	Subject string
+}
+
+// detectRevert reports whether the staged changes revert a recent commit, either because
+// a `git revert` is in progress or because the diff exactly undoes one of the recent commits
+func detectRevert(diff string) (RevertInfo, bool) {
+	if sha := readRevertHead(); sha != "" {
+		Log(DEBUG, "Found REVERT_HEAD: %s", sha)
+		return RevertInfo{SHA: sha, Subject: commitSubject(sha)}, true
+	}
+
+	staged := changedLines(diff)
+	if len(staged) == 0 {
+		return RevertInfo{}, false
+	}
+
+	output, err := gitCommand("", "log", fmt.Sprintf("--max-count=%d", revertSearchDepth), "--no-merges", "--format=%H").Output()
+	if err != nil {
+		Log(DEBUG, "Could not list recent commits for revert detection: %v", err)
+		return RevertInfo{}, false
+	}
+	for _, sha := range strings.Fields(string(output)) {
+		reversed, err := gitCommand("", "show", "-R", "--format=", sha).Output()
+		if err != nil {
+			continue
+		}
+		if equalLines(staged, changedLines(string(reversed))) {
+			Log(DEBUG, "Staged diff reverts commit %s", sha)
+			return RevertInfo{SHA: sha, Subject: commitSubject(sha)}, true
+		}
+	}
+	return RevertInfo{}, false
+}
+
+// readRevertHead returns the SHA in REVERT_HEAD when a revert is in progress
+func readRevertHead() string {
+	path, err := gitCommand("", "rev-parse", "--git-path", "REVERT_HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	revertPath := strings.TrimSpace(string(path))
+	if repoDir != "" && !filepath.IsAbs(revertPath) {
+		revertPath = filepath.Join(repoDir, revertPath)
+	}
+	data, err := ioutil.ReadFile(revertPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// commitSubject returns the subject line of a commit
+func commitSubject(sha string) string {
+	output, err := gitCommand("", "log", "-1", "--format=%s", sha).Output()
+	if err != nil {
+		Log(WARN, "Could not read subject of commit %s: %v", sha, err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// changedLines returns the added and removed lines of a diff, ignoring file headers and context
+func changedLines(diff string) []string {
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// revertInstruction returns the prompt instruction for a revert commit
+func revertInstruction(revert RevertInfo, configured string) string {
+	prompt := configured
+	if prompt == "" {
+		prompt = defaultRevertPrompt
+	}
+	// Replaced rather than formatted, so a "%" in the prompt or the subject is kept as-is
+	return strings.NewReplacer(revertSubjectVariable, revert.Subject, revertSHAVariable, revert.SHA).Replace(prompt)
+}
+
+// enforceRevertFormat makes sure a revert message has git's standard subject and "This reverts commit" line
+func enforceRevertFormat(message string, revert RevertInfo) string {
+	subject := fmt.Sprintf("Revert \"%s\"", revert.Subject)
+	_, body := splitTitleAndBody(message)
+	reverts := fmt.Sprintf("This reverts commit %s.", revert.SHA)
+	if !strings.Contains(body, reverts) {
+		body = strings.TrimSpace(reverts + "\n\n" + body)
+	}
+	return subject + "\n\n" + body
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRevertInstruction(t *testing.T) {
+	revert := RevertInfo{Subject: "Raise the limit to 100%", SHA: "abc1234"}
+
+	got := revertInstruction(revert, "Revert {{sha}} ({{subject}}), mentioning {{sha}} twice")
+	if want := "Revert abc1234 (Raise the limit to 100%), mentioning abc1234 twice"; got != want {
+		t.Errorf("revertInstruction() = %q, want %q", got, want)
+	}
+
+	got = revertInstruction(revert, "")
+	if !strings.Contains(got, `Revert "Raise the limit to 100%"`) || !strings.Contains(got, "This reverts commit abc1234.") {
+		t.Errorf("default revertInstruction() = %q, want the subject and SHA filled in", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reviewDelimiter separates the message from the diff in a -review file. Everything from
+// this line down is ignored when the message is read back.
+const reviewDelimiter = "<!-- >8 Everything below this line is ignored. Edit the message above it. -->"
+
+// reviewDocument builds the markdown file opened by -review: the message on top and the
+// staged diff below the delimiter, in a code block
+func reviewDocument(message string, diff string) string {
+	// The fence must be longer than any run of backticks in the diff
+	fence := "```"
+	for strings.Contains(diff, fence) {
+		fence += "`"
+	}
+	return fmt.Sprintf("%s\n\n%s\n\n%sdiff\n%s\n%s\n",
+		strings.TrimRight(message, "\n"), reviewDelimiter, fence, strings.TrimRight(diff, "\n"), fence)
+}
+
+// extractReviewedMessage rewrites an edited -review file with only the message above the delimiter
+func extractReviewedMessage(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read review file: %v", err)
+	}
+	var message []string
+	found := false
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == reviewDelimiter {
+			found = true
+			break
+		}
+		message = append(message, line)
+	}
+	if !found {
+		return fmt.Errorf("the line %q was removed from the review file, so the message can't be told apart from the diff", reviewDelimiter)
+	}
+	Log(DEBUG, "Extracted %d message lines from review file", len(message))
+	if err := os.WriteFile(path, []byte(strings.TrimSpace(strings.Join(message, "\n"))+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write message file: %v", err)
+	}
+	return nil
+}
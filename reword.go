@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RewordProposal is a generated replacement message for an existing commit
+type RewordProposal struct {
+	SHA             string
+	OriginalSubject string
+	OriginalMessage string
+	Message         string
+}
+
+// rewordMarker matches the lines separating commits in the reword review file
+var rewordMarker = regexp.MustCompile(`^=== ([0-9a-f]{7,40}) .*===$`)
+
+// getLastCommits returns the SHAs of the last n commits on HEAD, oldest first.
+// Merge commits are rejected since they can't be reworded by a simple rebase.
+func getLastCommits(n int) ([]string, error) {
+	output, err := gitCommand("", "rev-list", "--parents", fmt.Sprintf("--max-count=%d", n), "HEAD").Output()
+	if err != nil {
+		Log(ERROR, "Failed to list commits: %v", err)
+		return nil, &GitError{Err: fmt.Errorf("failed to list commits: %v", err)}
+	}
+	var shas []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("commit %s is a merge commit; -reword-last can't reword across merges", fields[0][:7])
+		}
+		shas = append([]string{fields[0]}, shas...)
+	}
+	if len(shas) < n {
+		return nil, fmt.Errorf("only %d commits found on HEAD", len(shas))
+	}
+	return shas, nil
+}
+
+// proposeRewords generates a new message for each commit from its diff
+func proposeRewords(shas []string, config Config) ([]RewordProposal, error) {
+	var proposals []RewordProposal
+	for i, sha := range shas {
+		fmt.Printf("Generating message for commit %d of %d (%s)...\n", i+1, len(shas), sha[:7])
+		diff, err := gitCommand("", "show", "--format=", sha).Output()
+		if err != nil {
+			Log(ERROR, "Failed to get diff for commit %s: %v", sha, err)
+			return nil, &GitError{Err: fmt.Errorf("failed to get diff for commit %s: %v", sha, err)}
+		}
+		original, err := gitCommand("", "log", "-1", "--format=%B", sha).Output()
+		if err != nil {
+			return nil, &GitError{Err: fmt.Errorf("failed to read the message of commit %s: %v", sha, err)}
+		}
+		proposal := RewordProposal{SHA: sha, OriginalSubject: commitSubject(sha), OriginalMessage: strings.TrimSpace(string(original))}
+		if strings.TrimSpace(string(diff)) == "" {
+			Log(INFO, "Commit %s has no changes, keeping its message", sha[:7])
+			proposals = append(proposals, proposal)
+			continue
+		}
+		proposal.Message, err = createCommitMessageForDiff(string(diff), config, false)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", sha[:7], err)
+		}
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+// reviewRewords opens all proposals in the editor so the user can approve or edit them.
+// A section left empty, or set back to the original message, keeps the commit as it is.
+func reviewRewords(proposals []RewordProposal) ([]RewordProposal, error) {
+	var sb strings.Builder
+	sb.WriteString("# Review the proposed messages below. Edit them as needed.\n")
+	sb.WriteString("# Leave a section empty to keep that commit's original message.\n")
+	sb.WriteString("# These lines are ignored. Don't edit the === lines.\n\n")
+	for _, proposal := range proposals {
+		sb.WriteString(fmt.Sprintf("=== %s (was: %s) ===\n", proposal.SHA[:12], proposal.OriginalSubject))
+		sb.WriteString(proposal.Message)
+		sb.WriteString("\n\n")
+	}
+
+	reviewFile := filepath.Join(tempDir(), fmt.Sprintf("git_reword_%d.txt", time.Now().Unix()))
+	if err := ioutil.WriteFile(reviewFile, []byte(sb.String()), 0644); err != nil {
+		Log(ERROR, "Failed to write review file: %v", err)
+		return nil, fmt.Errorf("failed to write review file: %v", err)
+	}
+	defer os.Remove(reviewFile)
+
+	if err := openInEditor(reviewFile); err != nil {
+		return nil, fmt.Errorf("failed to open editor: %w", err)
+	}
+	data, err := ioutil.ReadFile(reviewFile)
+	if err != nil {
+		Log(ERROR, "Failed to read review file: %v", err)
+		return nil, fmt.Errorf("failed to read review file: %v", err)
+	}
+
+	edited := map[string]string{}
+	current := ""
+	var lines []string
+	flush := func() {
+		if current != "" {
+			edited[current] = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Only the header comments come before the first marker; a "#" line within a
+		// message is kept, e.g. a Markdown heading or an issue reference
+		if current == "" && strings.HasPrefix(line, "#") {
+			continue
+		}
+		if match := rewordMarker.FindStringSubmatch(line); match != nil {
+			flush()
+			current = match[1]
+			lines = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	for i := range proposals {
+		for prefix, message := range edited {
+			if !strings.HasPrefix(proposals[i].SHA, prefix) {
+				continue
+			}
+			if message == proposals[i].OriginalMessage {
+				message = ""
+			}
+			proposals[i].Message = message
+		}
+	}
+	return proposals, nil
+}
+
+// applyRewords rewrites the commit messages with an automated interactive rebase:
+// each commit is picked and then amended with its new message by an exec step
+func applyRewords(proposals []RewordProposal, noVerify bool) error {
+	workDir, err := ioutil.TempDir(tempDir(), "gitscribe_reword")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	amendArgs := "--amend"
+	if noVerify {
+		amendArgs += " --no-verify"
+	}
+
+	var todo strings.Builder
+	for i, proposal := range proposals {
+		todo.WriteString(fmt.Sprintf("pick %s\n", proposal.SHA))
+		if proposal.Message == "" {
+			continue
+		}
+		messageFile := filepath.Join(workDir, fmt.Sprintf("message_%d.txt", i))
+		if err := ioutil.WriteFile(messageFile, []byte(proposal.Message), 0644); err != nil {
+			return fmt.Errorf("failed to write message file: %v", err)
+		}
+		todo.WriteString(fmt.Sprintf("exec git commit %s -F %s\n", amendArgs, shellQuote(messageFile)))
+	}
+	todoFile := filepath.Join(workDir, "todo")
+	if err := ioutil.WriteFile(todoFile, []byte(todo.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write rebase todo: %v", err)
+	}
+	Log(DEBUG, "Rebase todo:\n%s", todo.String())
+
+	// Rebase onto the parent of the oldest commit, or from the root if it has none
+	args := []string{"rebase", "-i", "--autostash"}
+	if err := gitCommand("", "rev-parse", "--verify", "--quiet", proposals[0].SHA+"^").Run(); err == nil {
+		args = append(args, proposals[0].SHA+"^")
+	} else {
+		args = append(args, "--root")
+	}
+
+	cmd := gitCommand("", args...)
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=cp "+shellQuote(todoFile), "GIT_EDITOR=true")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		Log(ERROR, "Rebase failed: %v", err)
+		return &GitError{Err: fmt.Errorf("rebase failed: %v (run 'git rebase --abort' to restore the original commits)", err)}
+	}
+	reworded := 0
+	for _, proposal := range proposals {
+		if proposal.Message != "" {
+			reworded++
+		}
+	}
+	sessionStats.recordCommits(reworded)
+	Log(INFO, "Reworded %d commits", reworded)
+	return nil
+}
+
+// shellQuote quotes a string for use as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// rewordLastCommits generates, reviews and applies new messages for the last n commits
+func rewordLastCommits(n int, config Config, noVerify bool) error {
+	Log(INFO, "Rewording the last %d commits", n)
+	shas, err := getLastCommits(n)
+	if err != nil {
+		return err
+	}
+	proposals, err := proposeRewords(shas, config)
+	if err != nil {
+		return err
+	}
+	proposals, err = reviewRewords(proposals)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\nNew messages:")
+	for _, proposal := range proposals {
+		subject := proposal.OriginalSubject + " (unchanged)"
+		if proposal.Message != "" {
+			subject, _ = splitTitleAndBody(proposal.Message)
+		}
+		fmt.Printf("  %s %s\n", proposal.SHA[:7], subject)
+	}
+	if !confirm("Rewrite these commits?") {
+		Log(INFO, "Reword aborted by user")
+		return fmt.Errorf("aborted")
+	}
+	return applyRewords(proposals, noVerify)
+}
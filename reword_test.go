@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// setEditor makes openInEditor run command on the file instead of an interactive editor
+func setEditor(t *testing.T, command string) {
+	t.Helper()
+	previous := editorOverride
+	editorOverride = command
+	t.Cleanup(func() { editorOverride = previous })
+}
+
+func TestReviewRewordsKeepsHashLinesInMessages(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	// Accepts the proposals as they are
+	setEditor(t, "true")
+	proposals := []RewordProposal{
+		{SHA: "1111111111111111", OriginalMessage: "Old message", Message: "Update docs\n\n# Usage\nSee #12"},
+		{SHA: "2222222222222222", OriginalMessage: "Fix typo", Message: "Fix typo"},
+	}
+
+	got, err := reviewRewords(proposals)
+	if err != nil {
+		t.Fatalf("reviewRewords() error: %v", err)
+	}
+	if want := "Update docs\n\n# Usage\nSee #12"; got[0].Message != want {
+		t.Errorf("first message = %q, want %q", got[0].Message, want)
+	}
+	if got[1].Message != "" {
+		t.Errorf("second message = %q, want it left unchanged", got[1].Message)
+	}
+}
+
+func TestApplyRewordsCountsRewrittenCommits(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a.txt", "a\n", "Add a")
+	commitFile(t, dir, "b.txt", "b\n", "Add b")
+	shas := strings.Fields(runGit(t, dir, "rev-list", "--reverse", "--max-count=2", "HEAD"))
+
+	before := sessionStats.Commits
+	proposals := []RewordProposal{
+		{SHA: shas[0], Message: "Add the a file"},
+		{SHA: shas[1]},
+	}
+	if err := applyRewords(proposals, false); err != nil {
+		t.Fatalf("applyRewords() error: %v", err)
+	}
+	if got := sessionStats.Commits - before; got != 1 {
+		t.Errorf("recorded %d commits, want 1", got)
+	}
+	if log := runGit(t, dir, "log", "--format=%s", "--max-count=2"); log != "Add b\nAdd the a file\n" {
+		t.Errorf("log = %q, want only the first commit reworded", log)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scissorsLine is git's cut line (after the comment character). When committing with
+// --cleanup=scissors, git drops it and everything below it from the message.
+const scissorsLine = "------------------------ >8 ------------------------"
+
+// commentChar returns git's core.commentChar, which starts the scissors line. With "auto"
+// git picks a character the message doesn't use; "#" is assumed then, as it is by default.
+func commentChar() string {
+	output, err := gitCommand("", "config", "core.commentChar").Output()
+	char := strings.TrimSpace(string(output))
+	if err != nil || char == "" || char == "auto" {
+		return "#"
+	}
+	return char
+}
+
+// scissorsDocument appends the diff below a scissors line, so it can be read while
+// editing and is removed by git when committing with --cleanup=scissors
+func scissorsDocument(message string, diff string, commentChar string) string {
+	return fmt.Sprintf("%s\n\n%s %s\n%s Do not modify or remove the line above.\n%s Everything below it will be ignored.\n%s",
+		strings.TrimRight(message, "\n"), commentChar, scissorsLine, commentChar, commentChar, diff)
+}
+
+// cutAtScissors returns the part of an edited message above its scissors line, if it has one
+func cutAtScissors(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasSuffix(line, " "+scissorsLine) && len([]rune(line)) == len([]rune(scissorsLine))+2 {
+			return strings.Join(lines[:i], "\n")
+		}
+	}
+	return content
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecretFinding is a likely secret in a diff. The matched text itself isn't kept, so it
+// can't end up in output or logs.
+type SecretFinding struct {
+	File string
+	Line int
+	Kind string
+}
+
+// secretPattern recognizes a well-known kind of secret
+type secretPattern struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// secretPatterns are formats of credentials that are unlikely to appear by accident
+var secretPatterns = []secretPattern{
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"OpenAI or Anthropic API key", regexp.MustCompile(`\bsk-(ant-|proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// secretKeywordPattern matches names that suggest a value is a credential. High-entropy
+// strings are only reported next to one, as hashes and checksums are common in diffs. A name
+// must start a word (as in AUTH_TOKEN) or a camelCase part (as in apiToken), so that e.g.
+// "oauth2" doesn't count.
+var secretKeywordPattern = regexp.MustCompile(`(?i:(^|[^a-z])(api[_-]?key|secret|token|passw(or)?d|credential|auth))|[a-z0-9](ApiKey|Secret|Token|Passw(or)?d|Credential|Auth)`)
+
+// highEntropyCandidatePattern matches strings long enough to be a generated secret. It doesn't
+// include "/", so URLs and paths are split into their parts.
+var highEntropyCandidatePattern = regexp.MustCompile(`[A-Za-z0-9+=_-]{20,}`)
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which a candidate
+// string looks randomly generated
+const minSecretEntropy = 4.0
+
+// diffHunkPattern extracts the start lines of both sides from a hunk header
+var diffHunkPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// scanDiffForSecrets looks for likely secrets in the added lines of a diff, numbered in the new
+// version of the file. Removed and context lines are already in the repository.
+func scanDiffForSecrets(diff string) []SecretFinding {
+	var findings []SecretFinding
+	for _, section := range splitDiffSections(diff) {
+		file := ""
+		if files := diffFiles(section); len(files) > 0 {
+			file = files[0]
+		}
+		newLine := 0
+		inHunk := false
+		for _, line := range strings.Split(section, "\n") {
+			if strings.HasPrefix(line, "@@") {
+				if m := diffHunkPattern.FindStringSubmatch(line); m != nil {
+					newLine, _ = strconv.Atoi(m[2])
+					inHunk = true
+				}
+				continue
+			}
+			if !inHunk {
+				// File header; a removed line may also start with "---" inside a hunk
+				if path := strings.TrimPrefix(line, "+++ "); path != line && path != "/dev/null" {
+					file = strings.TrimPrefix(path, "b/")
+				} else if path := strings.TrimPrefix(line, "--- "); path != line && path != "/dev/null" {
+					file = strings.TrimPrefix(path, "a/")
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if kind, found := detectSecret(line[1:]); found {
+					findings = append(findings, SecretFinding{File: file, Line: newLine, Kind: kind})
+				}
+				newLine++
+			case strings.HasPrefix(line, " "):
+				newLine++
+			}
+		}
+	}
+	return findings
+}
+
+// detectSecret reports the kind of secret a line appears to contain, if any
+func detectSecret(line string) (string, bool) {
+	for _, pattern := range secretPatterns {
+		if pattern.Pattern.MatchString(line) {
+			return pattern.Kind, true
+		}
+	}
+	if secretKeywordPattern.MatchString(line) {
+		for _, candidate := range highEntropyCandidatePattern.FindAllString(line, -1) {
+			if characterClasses(candidate) >= 2 && shannonEntropy(candidate) >= minSecretEntropy {
+				return "high-entropy string", true
+			}
+		}
+	}
+	return "", false
+}
+
+// characterClasses counts which of lowercase letters, uppercase letters and digits s contains.
+// Generated secrets mix them, unlike words joined by dashes or underscores.
+func characterClasses(s string) int {
+	var lower, upper, digit int
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = 1
+		case r >= 'A' && r <= 'Z':
+			upper = 1
+		case r >= '0' && r <= '9':
+			digit = 1
+		}
+	}
+	return lower + upper + digit
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestDetectSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{"go.sum hash of an oauth module", "golang.org/x/oauth2 v0.21.0 h1:tsimM75w1tF/uws5rbeHzIWxEqElMehnc+iW793zsZs=", false},
+		{"URL in a comment", "// See https://example.com/docs/authentication/refresh-tokens-overview", false},
+		{"words joined by dashes", `token := "refresh-token-for-the-service"`, false},
+		{"generated value", `apiToken := "q8Zt2LmX9vR4bN7cK1pW5sY3"`, true},
+		{"environment variable", "AUTH_TOKEN=Zk3n9QwX7pL2vR8tM4bY6cJ1", true},
+		{"GitHub token", "ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789", true},
+	}
+	for _, tt := range tests {
+		if _, got := detectSecret(tt.line); got != tt.want {
+			t.Errorf("%s: detectSecret(%q) = %v, want %v", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestScanDiffForSecretsOnlyAddedLines(t *testing.T) {
+	diff := `diff --git a/config.env b/config.env
+--- a/config.env
++++ b/config.env
+@@ -1,3 +1,3 @@
+ AUTH_TOKEN=Zk3n9QwX7pL2vR8tM4bY6cJ1
+-SECRET=Hj8kL2mN4pQ6rS8tU0vW2xY4
++SECRET=Ab3dE5fG7hJ9kL1mN3pQ5rS7
+`
+	findings := scanDiffForSecrets(diff)
+	if len(findings) != 1 || findings[0].File != "config.env" || findings[0].Line != 2 {
+		t.Errorf("scanDiffForSecrets() = %+v, want one finding at config.env:2", findings)
+	}
+}
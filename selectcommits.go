@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// selectCommits shows a checklist of the commits' subjects and lets the user toggle which ones to keep.
+// All commits start selected, so pressing Enter right away keeps the current behavior.
+func selectCommits(messages []string) ([]string, error) {
+	selected := make([]bool, len(messages))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Println("\nCommits to include in the PR description:")
+		for i, message := range messages {
+			mark := " "
+			if selected[i] {
+				mark = "x"
+			}
+			fmt.Printf("  [%s] %d. %s\n", mark, i+1, strings.SplitN(message, "\n", 2)[0])
+		}
+		fmt.Print("Toggle commits by number (e.g. 2 4), or press Enter to continue: ")
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" || err != nil {
+			break
+		}
+		for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+			n, convErr := strconv.Atoi(field)
+			if convErr != nil || n < 1 || n > len(messages) {
+				fmt.Printf("Ignoring %q: expected a number between 1 and %d\n", field, len(messages))
+				continue
+			}
+			selected[n-1] = !selected[n-1]
+		}
+	}
+
+	var kept []string
+	for i, message := range messages {
+		if selected[i] {
+			kept = append(kept, message)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("no commits selected")
+	}
+	Log(INFO, "Using %d of %d commits for the PR description", len(kept), len(messages))
+	return kept, nil
+}
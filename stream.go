@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// StreamOptions asks the API to report token usage at the end of a streamed response
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatStreamChunk is one server-sent event of a streamed chat completions response
+type ChatStreamChunk struct {
+	Choices []struct {
+		Delta        ChatMessage `json:"delta"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// chatStreamResult is the response assembled from a chat completions stream
+type chatStreamResult struct {
+	Content      string
+	FinishReason string
+	Usage        *Usage
+}
+
+// sendStreamingChatRequest sends a chat completions request with streaming enabled, printing
+// the response to stdout as it arrives, and returns the full response content
+func sendStreamingChatRequest(requestBody ChatRequest, config LLMConfig) (string, error) {
+	requestBody.Stream = true
+	requestBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL(config, "/chat/completions"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	setAuthHeaders(req, config)
+
+	release := acquireRequestSlot()
+	defer release()
+
+	var result chatStreamResult
+	_, body, err := streamHTTPRequest(req, config, func(r io.Reader) error {
+		var err error
+		result, err = readChatStream(r, os.Stdout)
+		fmt.Println()
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// An unsuccessful response carries a regular JSON error instead of a stream
+	if body != nil {
+		var chatResponse ChatResponse
+		if err := json.Unmarshal(body, &chatResponse); err != nil {
+			return "", fmt.Errorf("failed to unmarshal response: %v", err)
+		}
+		if chatResponse.Error != nil {
+			return "", &APIError{Err: fmt.Errorf("API error: %s", chatResponse.Error.Message)}
+		}
+		return "", &APIError{Err: fmt.Errorf("unexpected API response: %s", strings.TrimSpace(string(body)))}
+	}
+
+	if result.Content == "" && result.FinishReason == "" {
+		return "", fmt.Errorf("no response from API")
+	}
+
+	if err := checkFinishReason(result.FinishReason, config); err != nil {
+		return "", err
+	}
+
+	var usage Usage
+	if result.Usage != nil {
+		usage = *result.Usage
+		Log(DEBUG, "Token usage: %d prompt, %d completion", usage.PromptTokens, usage.CompletionTokens)
+	}
+	recordUsage(config, usage)
+
+	return result.Content, nil
+}
+
+// readChatStream parses the data: events of a chat completions stream, writing each piece
+// of content to out as it arrives, until the [DONE] event or the end of the stream
+func readChatStream(r io.Reader, out io.Writer) (chatStreamResult, error) {
+	var result chatStreamResult
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Blank lines separate events; other fields such as event: and comments aren't used
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return result, fmt.Errorf("failed to unmarshal stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return result, &APIError{Err: fmt.Errorf("API error: %s", chunk.Error.Message)}
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				fmt.Fprint(out, choice.Delta.Content)
+			}
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+		}
+	}
+	result.Content = content.String()
+	return result, scanner.Err()
+}
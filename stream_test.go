@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const chatStream = `data: {"choices": [{"delta": {"role": "assistant", "content": "Add "}}]}
+
+: keep-alive
+data: {"choices": [{"delta": {"content": "retries"}}]}
+
+data: {"choices": [{"delta": {}, "finish_reason": "stop"}]}
+
+data: {"choices": [], "usage": {"prompt_tokens": 50, "completion_tokens": 2, "total_tokens": 52}}
+
+data: [DONE]
+
+`
+
+func TestReadChatStream(t *testing.T) {
+	var out bytes.Buffer
+	result, err := readChatStream(strings.NewReader(chatStream), &out)
+	if err != nil {
+		t.Fatalf("readChatStream() error: %v", err)
+	}
+	if result.Content != "Add retries" || out.String() != "Add retries" {
+		t.Errorf("readChatStream() content = %q, printed %q, want %q", result.Content, out.String(), "Add retries")
+	}
+	if result.FinishReason != "stop" {
+		t.Errorf("finish reason = %q, want stop", result.FinishReason)
+	}
+	if result.Usage == nil || result.Usage.TotalTokens != 52 {
+		t.Errorf("usage = %+v, want the final usage chunk", result.Usage)
+	}
+}
+
+func TestReadChatStreamError(t *testing.T) {
+	stream := "data: {\"choices\": [{\"delta\": {\"content\": \"Add\"}}]}\n\ndata: {\"error\": {\"message\": \"server overloaded\"}}\n\n"
+	_, err := readChatStream(strings.NewReader(stream), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "server overloaded") {
+		t.Errorf("readChatStream() error = %v, want the streamed error", err)
+	}
+}
+
+func TestSendStreamingChatRequest(t *testing.T) {
+	var request ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(chatStream))
+	}))
+	defer server.Close()
+
+	var usage Usage
+	config := LLMConfig{APIKey: "test", BaseURL: server.URL, Model: "gpt-test", UsageTotal: &usage}
+	got, err := sendStreamingChatRequest(ChatRequest{Model: "gpt-test"}, config)
+	if err != nil {
+		t.Fatalf("sendStreamingChatRequest() error: %v", err)
+	}
+	if got != "Add retries" {
+		t.Errorf("sendStreamingChatRequest() = %q, want %q", got, "Add retries")
+	}
+	if !request.Stream || request.StreamOptions == nil || !request.StreamOptions.IncludeUsage {
+		t.Errorf("request = %+v, want streaming with usage", request)
+	}
+	if usage.TotalTokens != 52 {
+		t.Errorf("usage = %+v, want the streamed usage recorded", usage)
+	}
+}
+
+func TestSendStreamingChatRequestErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "model not found"}}`))
+	}))
+	defer server.Close()
+
+	config := LLMConfig{APIKey: "test", BaseURL: server.URL, Model: "gpt-test"}
+	_, err := sendStreamingChatRequest(ChatRequest{Model: "gpt-test"}, config)
+	if err == nil || !strings.Contains(err.Error(), "API error: model not found") {
+		t.Errorf("sendStreamingChatRequest() error = %v, want the API's error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Commit styles that can be configured with commit_style or detected from history
+const (
+	StyleAuto         = "auto"
+	StyleConventional = "conventional"
+	StyleGitmoji      = "gitmoji"
+	StyleFreeform     = "freeform"
+)
+
+// styleSampleSize is the number of recent commit subjects sampled when detecting the style
+const styleSampleSize = 50
+
+// styleThreshold is the fraction of sampled subjects that must match a style for it to be detected
+const styleThreshold = 0.6
+
+var (
+	conventionalSubjectPattern = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: \S`)
+	gitmojiShortcodePattern    = regexp.MustCompile(`^:[a-z0-9_+-]+:`)
+)
+
+// getRecentSubjects returns the subjects of the most recent commits
+func getRecentSubjects(n int) ([]string, error) {
+	cmd := gitCommand("", "log", fmt.Sprintf("--max-count=%d", n), "--format=%s")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+// classifySubjects heuristically determines the commit style used by a set of subjects
+func classifySubjects(subjects []string) string {
+	if len(subjects) == 0 {
+		return StyleFreeform
+	}
+	conventional, gitmoji := 0, 0
+	for _, subject := range subjects {
+		if conventionalSubjectPattern.MatchString(subject) {
+			conventional++
+		} else if startsWithEmoji(subject) || gitmojiShortcodePattern.MatchString(subject) {
+			gitmoji++
+		}
+	}
+	total := float64(len(subjects))
+	switch {
+	case float64(conventional)/total >= styleThreshold:
+		return StyleConventional
+	case float64(gitmoji)/total >= styleThreshold:
+		return StyleGitmoji
+	}
+	return StyleFreeform
+}
+
+// startsWithEmoji reports whether s begins with a character from the common emoji ranges
+func startsWithEmoji(s string) bool {
+	for _, r := range s {
+		return (r >= 0x1F300 && r <= 0x1FAFF) || (r >= 0x2600 && r <= 0x27BF)
+	}
+	return false
+}
+
+// resolveCommitStyle returns the configured commit style, detecting it from history when set to auto
+func resolveCommitStyle(configured string) string {
+	style := strings.ToLower(strings.TrimSpace(configured))
+	if style != "" && style != StyleAuto {
+		Log(DEBUG, "Using configured commit style: %s", style)
+		return style
+	}
+
+	subjects, err := getRecentSubjects(styleSampleSize)
+	if err != nil {
+		Log(DEBUG, "Could not sample commit history, assuming freeform style: %v", err)
+		return StyleFreeform
+	}
+	style = classifySubjects(subjects)
+	Log(DEBUG, "Detected commit style %s from %d recent subjects", style, len(subjects))
+	return style
+}
+
+// commitStyleInstruction returns the prompt instruction for a commit style, if it needs one
+func commitStyleInstruction(style string) string {
+	switch style {
+	case StyleConventional:
+		return `This repository uses Conventional Commits. Instead of the first line format described above,
+	the first line MUST be structured as: <type>(<optional scope>): <description>
+	where type is one of feat, fix, docs, style, refactor, perf, test, build, ci, chore or revert.
+	Example: fix(auth): handle expired refresh tokens`
+	case StyleGitmoji:
+		return `This repository uses gitmoji. Start the first line with a single emoji that matches the
+	kind of change (for example 🐛 for a bug fix, ✨ for a new feature, 📝 for documentation).`
+	}
+	return ""
+}
+
+// Tenses that can be configured for commit subjects
+const (
+	TenseImperative = "imperative"
+	TensePast       = "past"
+	TensePresent    = "present"
+)
+
+// tenseInstruction returns the prompt instruction for the configured tense
+func tenseInstruction(tense string) string {
+	switch tense {
+	case TensePast:
+		return `Write the commit message in the past tense, e.g. "Added retry logic" rather than "Add retry logic".
+	This overrides the tense used in any examples above.`
+	case TensePresent:
+		return `Write the commit message in the present tense, e.g. "Adds retry logic" rather than "Add retry logic".
+	This overrides the tense used in any examples above.`
+	}
+	return `Write the commit message in the imperative mood, e.g. "Add retry logic" rather than "Added retry logic"
+	or "Adds retry logic". This overrides the tense used in any examples above.`
+}
+
+// irregularPastVerbs are common past tense verbs that don't end in "ed". Verbs whose past
+// tense is the same as the imperative, like "set", "put" and "split", are left out.
+var irregularPastVerbs = map[string]bool{
+	"built": true, "made": true, "wrote": true, "ran": true, "kept": true, "began": true,
+	"broke": true, "chose": true, "did": true,
+}
+
+// detectTense makes a heuristic guess at the tense of a subject line from its first word,
+// skipping any "scope:" or "type(scope):" prefix, leading type tags like "[fix]" and gitmoji
+func detectTense(subject string) string {
+	if idx := strings.LastIndex(subject, ": "); idx != -1 {
+		subject = subject[idx+2:]
+	}
+	words := strings.Fields(subject)
+	for len(words) > 0 && (strings.HasPrefix(words[0], "[") || startsWithEmoji(words[0]) || gitmojiShortcodePattern.MatchString(words[0])) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	word := strings.ToLower(strings.Trim(words[0], ".,:;!\"'`"))
+	switch {
+	case strings.HasSuffix(word, "ed") || irregularPastVerbs[word]:
+		return TensePast
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return TensePresent
+	}
+	return TenseImperative
+}
+
+// checkTense warns when the subject of a message doesn't appear to use the configured tense
+func checkTense(message string, tense string) {
+	subject, _ := splitTitleAndBody(message)
+	detected := detectTense(subject)
+	if detected == "" || detected == tense {
+		return
+	}
+	Log(WARN, "Commit subject appears to use the %s tense, expected %s: %s", detected, tense, subject)
+	fmt.Printf("Warning: the subject line appears to use the %s tense rather than the configured %s tense.\n", detected, tense)
+}
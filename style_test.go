@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestDetectTense(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"Add retry logic", TenseImperative},
+		{"Added retry logic", TensePast},
+		{"Adds retry logic", TensePresent},
+		{"Wrote the changelog", TensePast},
+		{"fix(api): Broke up the client", TensePast},
+		// Their past tense is the same as the imperative, so they read as imperative
+		{"Set the default timeout", TenseImperative},
+		{"Put the cache behind a flag", TenseImperative},
+		{"Split the parser into two files", TenseImperative},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectTense(tt.subject); got != tt.want {
+			t.Errorf("detectTense(%q) = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
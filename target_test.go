@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestResolveTargetBranch(t *testing.T) {
+	tests := []struct {
+		name     string
+		branch   string // the repository's only branch
+		ghScript string
+		want     string
+	}{
+		{"reported by gh", "main", `echo trunk`, "trunk"},
+		{"existing main", "main", `exit 1`, "main"},
+		{"existing master", "master", `exit 1`, "master"},
+		{"neither exists", "develop", `exit 1`, "master"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			if tt.branch != "main" {
+				runGit(t, dir, "branch", "-m", "main", tt.branch)
+			}
+			stubCommand(t, "gh", tt.ghScript)
+			if got := resolveTargetBranch("", "", ""); got != tt.want {
+				t.Errorf("resolveTargetBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTargetBranchPrecedence(t *testing.T) {
+	dir := newTestRepo(t)
+	stubCommand(t, "gh", `echo trunk`)
+	runGit(t, dir, "update-ref", "refs/remotes/origin/release", "HEAD")
+	runGit(t, dir, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/release")
+
+	if got := resolveTargetBranch("", "", ""); got != "release" {
+		t.Errorf("resolveTargetBranch() = %q, want origin/HEAD's branch release", got)
+	}
+	if got := resolveTargetBranch("", "", "stable"); got != "stable" {
+		t.Errorf("resolveTargetBranch() with a configured branch = %q, want stable", got)
+	}
+	if got := resolveTargetBranch("", "next", "stable"); got != "next" {
+		t.Errorf("resolveTargetBranch() with -target = %q, want next", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// TemplateCandidate is a place a commit or PR template can come from
+type TemplateCandidate struct {
+	Kind   string // "commit" or "pr"
+	Source string // Where the candidate was found, e.g. "config (commit_template)"
+	Spec   string // Path, notes:<ref> spec or inline content, as accepted by readTemplate
+}
+
+// templateCandidates returns the template candidates for each kind in order of precedence.
+// The first candidate of a kind that can be read is the one used.
+func templateCandidates(config Config) []TemplateCandidate {
+	var candidates []TemplateCandidate
+	if config.CommitTemplate != "" {
+		candidates = append(candidates, TemplateCandidate{Kind: "commit", Source: "config (commit_template)", Spec: config.CommitTemplate})
+	} else if path := gitCommitTemplate(); path != "" {
+		candidates = append(candidates, TemplateCandidate{Kind: "commit", Source: "git config (commit.template)", Spec: path})
+	}
+	if config.PRTemplate != "" {
+		candidates = append(candidates, TemplateCandidate{Kind: "pr", Source: "config (pr_template)", Spec: config.PRTemplate})
+	}
+	if path := repoPRTemplate(""); path != "" && path != config.PRTemplate {
+		candidates = append(candidates, TemplateCandidate{Kind: "pr", Source: "repository", Spec: path})
+	}
+	return candidates
+}
+
+// printTemplates lists the template candidates, whether each can be read, and which one
+// would be selected for each kind
+func printTemplates(config Config) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tSELECTED\tSOURCE\tTEMPLATE\tSTATUS")
+	selected := map[string]bool{}
+	for _, candidate := range templateCandidates(config) {
+		status, mark := "ok", ""
+		if _, err := readTemplate(candidate.Spec); err != nil {
+			status = "unreadable: " + err.Error()
+		} else if !selected[candidate.Kind] {
+			selected[candidate.Kind] = true
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", candidate.Kind, mark, candidate.Source, templateLabel(candidate.Spec), status)
+	}
+	w.Flush()
+
+	for _, kind := range []string{"commit", "pr"} {
+		if !selected[kind] {
+			fmt.Printf("No readable %s template found\n", kind)
+		}
+	}
+}
+
+// gitCommitTemplate returns the file set as git's commit.template, or "" if none is set.
+// Like git, a relative path is taken relative to the repository being committed to.
+func gitCommitTemplate() string {
+	output, err := gitCommand("", "config", "--path", "commit.template").Output()
+	if err != nil {
+		// git config exits non-zero when the key is unset
+		return ""
+	}
+	path := strings.TrimSpace(string(output))
+	if path != "" && !filepath.IsAbs(path) && repoDir != "" {
+		path = filepath.Join(repoDir, path)
+	}
+	return path
+}
+
+// repoPRTemplatePaths are the places, relative to the repository root, where GitHub looks
+// for a pull request template, in order of precedence
+var repoPRTemplatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// repoPRTemplate returns the PR template the repository in dir ships with, or "" if it has none
+func repoPRTemplate(dir string) string {
+	output, err := gitCommand(dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	root := strings.TrimSpace(string(output))
+	for _, rel := range repoPRTemplatePaths {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// resolvePRTemplate returns the PR template to use for the repository in dir: the configured
+// one if it can be read, otherwise the repository's own template if it has one
+func resolvePRTemplate(dir string, configured string) string {
+	if configured != "" {
+		if _, err := readTemplate(configured); err == nil {
+			return configured
+		}
+	}
+	path := repoPRTemplate(dir)
+	if path == "" {
+		// Leave reporting a missing or unreadable template to readTemplate
+		return configured
+	}
+	if configured != "" {
+		Log(WARN, "PR template %s can't be read", templateLabel(configured))
+	}
+	Log(INFO, "Using the repository's PR template: %s", path)
+	return path
+}
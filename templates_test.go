@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoPRTemplate(t *testing.T) {
+	dir := newTestRepo(t)
+	if got := repoPRTemplate(dir); got != "" {
+		t.Errorf("repoPRTemplate() = %q, want none in a repository without a template", got)
+	}
+
+	writeFile(t, dir, "docs/pull_request_template.md", "## Docs\n")
+	if got, want := repoPRTemplate(dir), filepath.Join(dir, "docs", "pull_request_template.md"); got != want {
+		t.Errorf("repoPRTemplate() = %q, want %q", got, want)
+	}
+
+	// .github takes precedence, and the template is found from a subdirectory
+	writeFile(t, dir, ".github/pull_request_template.md", "## Summary\n")
+	writeFile(t, dir, "src/main.go", "package main\n")
+	if got, want := repoPRTemplate(filepath.Join(dir, "src")), filepath.Join(dir, ".github", "pull_request_template.md"); got != want {
+		t.Errorf("repoPRTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestResolvePRTemplate(t *testing.T) {
+	dir := newTestRepo(t)
+	repoTemplate := filepath.Join(dir, ".github", "pull_request_template.md")
+	writeFile(t, dir, ".github/pull_request_template.md", "## Summary\n")
+	configured := filepath.Join(t.TempDir(), "pr.md")
+	writeFile(t, filepath.Dir(configured), "pr.md", "## Changes\n")
+
+	tests := []struct {
+		configured string
+		want       string
+	}{
+		{configured, configured},
+		{"", repoTemplate},
+		// An unreadable configured template falls back to the repository's
+		{filepath.Join(t.TempDir(), "missing.md"), repoTemplate},
+	}
+	for _, tt := range tests {
+		if got := resolvePRTemplate(dir, tt.configured); got != tt.want {
+			t.Errorf("resolvePRTemplate(%q) = %q, want %q", tt.configured, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+)
+
+// Template variables filled in by GitScribe rather than the LLM
+const (
+	diffStatVariable     = "{{diffstat}}"
+	changedFilesVariable = "{{changed_files}}"
+)
+
+// templateVariablesInstruction asks the LLM to leave the template variables for GitScribe to fill in
+const templateVariablesInstruction = "The template contains the placeholders " + diffStatVariable + " and/or " +
+	changedFilesVariable + ". Copy them into the message verbatim where they appear in the template; " +
+	"they are replaced with the actual diffstat and file list afterwards."
+
+// usesTemplateVariables reports whether the template contains any of the template variables
+func usesTemplateVariables(template string) bool {
+	return strings.Contains(template, diffStatVariable) || strings.Contains(template, changedFilesVariable)
+}
+
+// expandTemplateVariables replaces the template variables in a message with the diffstat and files.
+// The diffstat is that of the staged changes, so it is only filled in if staged is set.
+func expandTemplateVariables(message string, files []string, staged bool) string {
+	if strings.Contains(message, diffStatVariable) && !staged {
+		Log(WARN, "Leaving %s as is: it is only filled in for staged changes", diffStatVariable)
+	} else if strings.Contains(message, diffStatVariable) {
+		diffStat, err := getStagedDiffStat()
+		if err != nil {
+			Log(WARN, "Could not fill in %s: %v", diffStatVariable, err)
+		} else {
+			message = strings.ReplaceAll(message, diffStatVariable, diffStat)
+		}
+	}
+	if strings.Contains(message, changedFilesVariable) {
+		message = strings.ReplaceAll(message, changedFilesVariable, "- "+strings.Join(files, "\n- "))
+	}
+	return message
+}
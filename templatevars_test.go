@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestExpandTemplateVariablesOutsideStagedChanges(t *testing.T) {
+	message := "Subject\n\n{{diffstat}}\n\n{{changed_files}}"
+	got := expandTemplateVariables(message, []string{"a.go", "b.go"}, false)
+	want := "Subject\n\n{{diffstat}}\n\n- a.go\n- b.go"
+	if got != want {
+		t.Errorf("expandTemplateVariables() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// estimateTokens roughly estimates the number of tokens in text (about 4 characters per token)
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// printTokenBreakdown prints estimated token counts for each part of the prompt:
+// the built-in instructions, the template, the main input (diff or commits) and any extra user context
+func printTokenBreakdown(systemPrompt string, template string, input string, inputLabel string, userContent string) {
+	instructions := estimateTokens(systemPrompt) - estimateTokens(template)
+	templateTokens := estimateTokens(template)
+	inputTokens := estimateTokens(input)
+	otherUser := estimateTokens(userContent) - inputTokens
+	if instructions < 0 {
+		instructions = 0
+	}
+	if otherUser < 0 {
+		otherUser = 0
+	}
+	total := instructions + templateTokens + inputTokens + otherUser
+
+	percent := func(n int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(total)
+	}
+
+	fmt.Println("=== Estimated Prompt Tokens ===")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "System instructions\t%d\t%.0f%%\t\n", instructions, percent(instructions))
+	fmt.Fprintf(w, "Template\t%d\t%.0f%%\t\n", templateTokens, percent(templateTokens))
+	fmt.Fprintf(w, "%s\t%d\t%.0f%%\t\n", inputLabel, inputTokens, percent(inputTokens))
+	fmt.Fprintf(w, "Other user content\t%d\t%.0f%%\t\n", otherUser, percent(otherUser))
+	fmt.Fprintf(w, "Total\t%d\t\t\n", total)
+	w.Flush()
+	fmt.Println("===============================")
+}
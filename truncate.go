@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// truncateDiff shortens a diff to about maxTokens (0 disables the limit). Files that fit in an
+// even share of the budget are kept whole, and what they leave over is shared evenly among the
+// larger ones, which are cut at a line boundary after their header and marked as truncated.
+// When even the headers don't fit, the files past the budget are left out and summarized in
+// a "N more files" line.
+func truncateDiff(diff string, maxTokens int) string {
+	if maxTokens <= 0 || estimateTokens(diff) <= maxTokens {
+		return diff
+	}
+	Log(WARN, "Diff is about %d tokens, truncating it to max_diff_tokens (%d)", estimateTokens(diff), maxTokens)
+
+	sections := splitDiffSections(diff)
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return len(sections[order[a]]) < len(sections[order[b]]) })
+
+	budget := maxTokens * 4 // estimateTokens counts about 4 characters per token
+	remaining := budget
+	allowed := make([]int, len(sections))
+	for n, i := range order {
+		share := remaining / (len(order) - n)
+		allowed[i] = len(sections[i])
+		if allowed[i] > share {
+			allowed[i] = share
+		}
+		remaining -= allowed[i]
+	}
+
+	var truncated []string
+	size := 0
+	for i, section := range sections {
+		kept := truncateDiffSection(section, allowed[i])
+		if i > 0 && size+len(kept)+1 > budget {
+			truncated = append(truncated, fmt.Sprintf("... %d more files changed, left out of the diff ...", len(sections)-i))
+			break
+		}
+		truncated = append(truncated, kept)
+		size += len(kept) + 1
+	}
+	return strings.Join(truncated, "\n")
+}
+
+// truncateDiffSection cuts one file's diff down to about maxChars, keeping its header lines
+func truncateDiffSection(section string, maxChars int) string {
+	if len(section) <= maxChars {
+		return section
+	}
+	lines := strings.Split(section, "\n")
+	var kept []string
+	size := 0
+	inHunks := false
+	marker := func(left int) string { return fmt.Sprintf("... truncated %d lines ...", left) }
+	for i, line := range lines {
+		inHunks = inHunks || strings.HasPrefix(line, "@@")
+		// Leave room for the marker after the line, unless it's the last one
+		needed := size + len(line) + 1
+		if i < len(lines)-1 {
+			needed += len(marker(len(lines) - i - 1))
+		}
+		if inHunks && needed > maxChars {
+			kept = append(kept, marker(len(lines)-i))
+			break
+		}
+		kept = append(kept, line)
+		size += len(line) + 1
+	}
+	return strings.Join(kept, "\n")
+}
+
+// truncateLines keeps the leading lines of text that fit in about maxTokens (0 disables the
+// limit), noting how many were left out
+func truncateLines(text string, maxTokens int) string {
+	if maxTokens <= 0 || estimateTokens(text) <= maxTokens {
+		return text
+	}
+	Log(WARN, "Input is about %d tokens, truncating it to max_diff_tokens (%d)", estimateTokens(text), maxTokens)
+	lines := strings.Split(text, "\n")
+	var kept []string
+	size := 0
+	for i, line := range lines {
+		if size+len(line)+1 > maxTokens*4 {
+			kept = append(kept, fmt.Sprintf("... truncated %d more lines ...", len(lines)-i))
+			break
+		}
+		kept = append(kept, line)
+		size += len(line) + 1
+	}
+	return strings.Join(kept, "\n")
+}
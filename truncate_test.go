@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fileDiff returns the diff of a new file with the given number of added lines
+func fileDiff(name string, lines int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\nnew file mode 100644\n--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@", name, name, name, lines)
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&sb, "\n+line %d of %s", i, name)
+	}
+	return sb.String()
+}
+
+func TestTruncateDiffWithinBudget(t *testing.T) {
+	diff := fileDiff("a.go", 3)
+	if got := truncateDiff(diff, 1000); got != diff {
+		t.Errorf("truncateDiff() = %q, want the diff unchanged", got)
+	}
+	if got := truncateDiff(diff, 0); got != diff {
+		t.Errorf("truncateDiff() with no limit = %q, want the diff unchanged", got)
+	}
+}
+
+func TestTruncateDiffCutsLargeFiles(t *testing.T) {
+	small := fileDiff("small.go", 2)
+	diff := small + "\n" + fileDiff("large.go", 200)
+
+	got := truncateDiff(diff, 200)
+	if !strings.HasPrefix(got, small+"\n") {
+		t.Errorf("truncateDiff() didn't keep the small file whole:\n%s", got)
+	}
+	if !strings.Contains(got, "+++ b/large.go") || !strings.Contains(got, "... truncated") {
+		t.Errorf("truncateDiff() didn't keep the large file's header and mark it truncated:\n%s", got)
+	}
+	if tokens := estimateTokens(got); tokens > 220 {
+		t.Errorf("truncated diff is about %d tokens, want about 200", tokens)
+	}
+}
+
+func TestTruncateDiffLeavesOutFilesPastBudget(t *testing.T) {
+	var files []string
+	for i := 0; i < 50; i++ {
+		files = append(files, fileDiff(fmt.Sprintf("file%d.go", i), 5))
+	}
+
+	got := truncateDiff(strings.Join(files, "\n"), 100)
+	if tokens := estimateTokens(got); tokens > 120 {
+		t.Errorf("truncated diff is about %d tokens, want about 100:\n%s", tokens, got)
+	}
+	if !strings.HasPrefix(got, "diff --git a/file0.go") {
+		t.Errorf("truncateDiff() didn't keep the first file:\n%s", got)
+	}
+	kept := strings.Count(got, "diff --git ")
+	if want := fmt.Sprintf("... %d more files changed, left out of the diff ...", 50-kept); !strings.HasSuffix(got, want) {
+		t.Errorf("truncateDiff() doesn't end with %q:\n%s", want, got)
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	text := strings.Repeat("0123456789abcde\n", 20)
+	got := truncateLines(text, 20)
+	if !strings.HasSuffix(got, "... truncated 16 more lines ...") {
+		t.Errorf("truncateLines() = %q, want the last 16 lines left out", got)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Usage is the token usage block returned by the chat completions API
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// SessionStats accumulates what GitScribe did during this run. It is only ever printed locally.
+type SessionStats struct {
+	mu               sync.Mutex
+	Start            time.Time
+	APICalls         int
+	PromptTokens     int
+	CompletionTokens int
+	Commits          int
+	PullRequests     int
+	ByModel          map[string]Usage
+}
+
+// sessionStats holds the statistics for the current run
+var sessionStats = &SessionStats{Start: time.Now()}
+
+// recordAPICall adds an API call and its token usage to the session statistics
+func (s *SessionStats) recordAPICall(model string, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.APICalls++
+	s.PromptTokens += usage.PromptTokens
+	s.CompletionTokens += usage.CompletionTokens
+
+	if s.ByModel == nil {
+		s.ByModel = make(map[string]Usage)
+	}
+	total := s.ByModel[model]
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.PromptTokens + usage.CompletionTokens
+	s.ByModel[model] = total
+}
+
+// recordUsage records the token usage of an API call made with config in the session
+// statistics and, if set, in config.UsageTotal
+func recordUsage(config LLMConfig, usage Usage) {
+	sessionStats.recordAPICall(config.Model, usage)
+	if config.UsageTotal != nil {
+		config.UsageTotal.PromptTokens += usage.PromptTokens
+		config.UsageTotal.CompletionTokens += usage.CompletionTokens
+		config.UsageTotal.TotalTokens += usage.PromptTokens + usage.CompletionTokens
+	}
+}
+
+// recordCommits adds created commits to the session statistics
+func (s *SessionStats) recordCommits(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Commits += n
+}
+
+// recordPullRequest adds a created pull request to the session statistics
+func (s *SessionStats) recordPullRequest() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PullRequests++
+}
+
+// summary returns a one-line summary of the session
+func (s *SessionStats) summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("Session: %d API calls, %d tokens (%d prompt + %d completion), %.1fs, %d commits, %d PRs created",
+		s.APICalls, s.PromptTokens+s.CompletionTokens, s.PromptTokens, s.CompletionTokens,
+		time.Since(s.Start).Seconds(), s.Commits, s.PullRequests)
+}
+
+// printUsageSummary prints the session summary if anything worth reporting happened
+func printUsageSummary() {
+	sessionStats.mu.Lock()
+	active := sessionStats.APICalls > 0 || sessionStats.Commits > 0 || sessionStats.PullRequests > 0
+	sessionStats.mu.Unlock()
+	if active {
+		fmt.Println(sessionStats.summary())
+	}
+}
+
+// showUsageSummary is set unless -quiet, for exit to print the summary that main defers
+var showUsageSummary bool
+
+// exit prints the usage summary unless -quiet, then exits with code. os.Exit skips deferred
+// calls, so main exits through this to report the API calls made before a failure.
+func exit(code int) {
+	if showUsageSummary {
+		printUsageSummary()
+	}
+	os.Exit(code)
+}
+
+// costReport describes the tokens used so far and what they cost, if the prices are known.
+// It returns an empty string if no API calls were made.
+func (s *SessionStats) costReport() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.APICalls == 0 {
+		return ""
+	}
+	report := fmt.Sprintf("Token usage: %d prompt + %d completion = %d tokens", s.PromptTokens, s.CompletionTokens, s.PromptTokens+s.CompletionTokens)
+	total := 0.0
+	var unknown []string
+	for model, usage := range s.ByModel {
+		cost, ok := estimateCost(model, usage)
+		if !ok {
+			unknown = append(unknown, model)
+			continue
+		}
+		total += cost
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return report + fmt.Sprintf(", estimated cost unknown (no price for %s; add it to model_prices)", strings.Join(unknown, ", "))
+	}
+	return report + fmt.Sprintf(", estimated cost $%.4f", total)
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// modelPrices lists the published prices of common models. Dated variants match by prefix.
+var modelPrices = map[string]ModelPrice{
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4.1":       {Input: 2, Output: 8},
+	"gpt-4.1-mini":  {Input: 0.4, Output: 1.6},
+	"gpt-4.1-nano":  {Input: 0.1, Output: 0.4},
+	"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+	"o1":            {Input: 15, Output: 60},
+	"o3-mini":       {Input: 1.1, Output: 4.4},
+	"o4-mini":       {Input: 1.1, Output: 4.4},
+}
+
+// setModelPrices adds prices from the config, replacing the built-in price of a model
+func setModelPrices(prices map[string]ModelPrice) {
+	for model, price := range prices {
+		modelPrices[model] = price
+	}
+}
+
+// estimateCost returns the cost in USD of the given usage, or false if the model's price is unknown
+func estimateCost(model string, usage Usage) (float64, bool) {
+	match := ""
+	for name := range modelPrices {
+		if (model == name || strings.HasPrefix(model, name+"-")) && len(name) > len(match) {
+			match = name
+		}
+	}
+	if match == "" {
+		return 0, false
+	}
+	price := modelPrices[match]
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6, true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatResponseUsage(t *testing.T) {
+	body := `{"choices": [{"message": {"role": "assistant", "content": "Add retries"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 1200, "completion_tokens": 80, "total_tokens": 1280}}`
+	var response ChatResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if response.Usage == nil {
+		t.Fatal("usage block not read")
+	}
+	if want := (Usage{PromptTokens: 1200, CompletionTokens: 80, TotalTokens: 1280}); *response.Usage != want {
+		t.Errorf("usage = %+v, want %+v", *response.Usage, want)
+	}
+
+	response = ChatResponse{}
+	if err := json.Unmarshal([]byte(`{"choices": []}`), &response); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if response.Usage != nil {
+		t.Errorf("usage = %+v, want nil when the response has none", *response.Usage)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	usage := Usage{PromptTokens: 1000000, CompletionTokens: 500000}
+	tests := []struct {
+		model string
+		want  float64
+		known bool
+	}{
+		{"gpt-4o", 2.5 + 5, true},
+		// Dated variants use the price of the longest matching model
+		{"gpt-4o-mini-2024-07-18", 0.15 + 0.3, true},
+		{"gpt-4o-2024-08-06", 2.5 + 5, true},
+		{"llama3", 0, false},
+		{"gpt-4oo", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := estimateCost(tt.model, usage)
+		if ok != tt.known || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("estimateCost(%q) = %v, %v, want %v, %v", tt.model, got, ok, tt.want, tt.known)
+		}
+	}
+}
+
+func TestCostReport(t *testing.T) {
+	stats := &SessionStats{Start: time.Now()}
+	if report := stats.costReport(); report != "" {
+		t.Errorf("costReport() = %q, want nothing before any API call", report)
+	}
+
+	stats.recordAPICall("gpt-4o-mini", Usage{PromptTokens: 2000, CompletionTokens: 1000})
+	if want := "Token usage: 2000 prompt + 1000 completion = 3000 tokens, estimated cost $0.0009"; stats.costReport() != want {
+		t.Errorf("costReport() = %q, want %q", stats.costReport(), want)
+	}
+
+	stats.recordAPICall("llama3", Usage{PromptTokens: 10, CompletionTokens: 5})
+	if report := stats.costReport(); !strings.Contains(report, "estimated cost unknown (no price for llama3") {
+		t.Errorf("costReport() = %q, want the cost reported as unknown", report)
+	}
+}